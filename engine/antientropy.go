@@ -0,0 +1,60 @@
+package engine
+
+// reconcile runs a round of anti-entropy if one is due: every node picks a
+// random peer and the two reconcile their state in both directions,
+// regardless of whether either of them just heard the rumour.
+func (s *Simulation) reconcile() {
+	if s.antiEntropy <= 0 || s.round%s.antiEntropy != 0 {
+		return
+	}
+
+	for id := range s.nodes {
+		if s.down(id) || s.dropping(id) {
+			continue
+		}
+		for _, peer := range s.randomPeers(id, 1) {
+			if !s.send(id, peer) {
+				continue
+			}
+
+			if s.timed() {
+				s.schedule(id, peer, true)
+				continue
+			}
+			s.exchange(id, peer)
+		}
+	}
+}
+
+// exchange reconciles the state of id and peer.
+func (s *Simulation) exchange(id, peer int) {
+	if s.digest && s.passable(id) == s.passable(peer) {
+		// digests match, nothing to transfer
+		return
+	}
+
+	if s.knew(id) {
+		s.Inform(peer, id, Repaired)
+	}
+	if s.offers(peer, id) {
+		s.Inform(id, peer, Repaired)
+	}
+}
+
+// antiEntropyOnly spreads nothing by itself, leaving anti-entropy as the only
+// way the rumour moves.
+type antiEntropyOnly struct{}
+
+func newAntiEntropyOnly() Protocol {
+	return antiEntropyOnly{}
+}
+
+func (antiEntropyOnly) Targets(s *Simulation, id int) []int {
+	return nil
+}
+
+func (antiEntropyOnly) Receive(s *Simulation, from, to int) {}
+
+func (antiEntropyOnly) Done(s *Simulation) bool {
+	return true
+}
@@ -0,0 +1,30 @@
+package engine
+
+import "testing"
+
+// Rumour mongering leaves some nodes uninformed once every node loses
+// interest, and anti-entropy repairs them.
+func TestAntiEntropyRepairs(t *testing.T) {
+	opts := []Option{WithSize(60, 30), WithNodes(200), WithSpread(1), WithSeed(5), WithProtocol("rumour coin/blind")}
+	without := finish(t, nil, opts...)
+	if without.Informed == without.Nodes {
+		t.Fatalf("rumour mongering alone informed all %d nodes, want some left to repair", without.Nodes)
+	}
+	for _, digest := range []bool{true, false} {
+		status := finish(t, nil, append(opts, WithAntiEntropy(3, digest))...)
+		if status.Informed != status.Nodes {
+			t.Errorf("digest %v: informed %d of %d nodes, want all", digest, status.Informed, status.Nodes)
+		}
+	}
+}
+
+// Anti-entropy alone spreads the rumour, however long its period, without
+// the run being taken for stuck between reconciliations.
+func TestAntiEntropyOnly(t *testing.T) {
+	for _, period := range []int{1, 200} {
+		status := finish(t, nil, WithSize(60, 30), WithNodes(150), WithSeed(3), WithProtocol("anti-entropy only"), WithAntiEntropy(period, true))
+		if status.Informed != status.Nodes {
+			t.Errorf("period %d: informed %d of %d nodes, want all", period, status.Informed, status.Nodes)
+		}
+	}
+}
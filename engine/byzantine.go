@@ -0,0 +1,107 @@
+package engine
+
+import "math"
+
+// Behaviour is how a node treats the rumours passing through it.
+type Behaviour int
+
+const (
+	Honest   Behaviour = iota // relays the rumour as the protocol says
+	Dropping                  // silently drops the rumour once it has it, never sending anything again
+	Lying                     // makes its contacts as if relaying, but never hands the rumour over
+	Forging                   // spreads a forged value of the rumour from the first round
+)
+
+// WithByzantine makes fraction of the nodes, between 0 and 1, misbehave.
+// Each picks one of behaviours at random, or of Dropping, Lying and Forging
+// if none are given. Honest nodes keep the first value of the rumour they
+// receive, so a node informed by a forger, or by a node a forger misled,
+// passes the forged value on.
+func WithByzantine(fraction float64, behaviours ...Behaviour) Option {
+	return func(s *Simulation) {
+		s.byzantine = fraction
+		s.byzantineBehaviours = behaviours
+	}
+}
+
+// Behaviour returns how id treats the rumour.
+func (s *Simulation) Behaviour(id int) Behaviour {
+	return s.behaviours[id]
+}
+
+// Forged reports whether id holds the forged value of the rumour.
+func (s *Simulation) Forged(id int) bool {
+	return s.forged[id]
+}
+
+// corrupt picks the nodes that misbehave, at random.
+func (s *Simulation) corrupt() {
+	count := int(math.Round(s.byzantine * float64(len(s.nodes))))
+	if count <= 0 {
+		return
+	}
+	behaviours := s.byzantineBehaviours
+	if len(behaviours) == 0 {
+		behaviours = []Behaviour{Dropping, Lying, Forging}
+	}
+
+	for _, id := range s.rand.Perm(len(s.nodes))[:min(count, len(s.nodes))] {
+		s.behaviours[id] = behaviours[s.rand.Intn(len(behaviours))]
+	}
+}
+
+// forge injects a forged value of every rumour at every forger that is
+// still up, as if each had been picked as a starting node. There is nothing
+// to forge under a protocol that spreads no rumour.
+func (s *Simulation) forge() {
+	if !s.NeedsStart() {
+		return
+	}
+	for id, behaviour := range s.behaviours {
+		if behaviour != Forging || s.states[id] != Susceptible {
+			continue
+		}
+		s.setState(id, Infected)
+		s.learn(id, s.all)
+		s.informedAt[id] = 0
+		s.via[id] = Started
+		s.heardAt[id] = 0 // so it can spread the forgery straight away
+		s.forged[id] = true
+		s.changes = append(s.changes, Change{Coord: s.coord(id), State: Infected, Via: Started, Forged: true, Rumours: s.held[id]})
+	}
+}
+
+// relays reports whether id hands the rumour over when it is asked or
+// meant to.
+func (s *Simulation) relays(id int) bool {
+	return s.behaviours[id] != Dropping && s.behaviours[id] != Lying
+}
+
+// dropping reports whether id has the rumour and drops it rather than
+// making any contact. Until it has it, a dropping node still asks for it.
+func (s *Simulation) dropping(id int) bool {
+	return s.behaviours[id] == Dropping && s.Informed(id)
+}
+
+// misled returns how many honest nodes that are still up hold the forged
+// value.
+func (s *Simulation) misled() int {
+	var count int
+	for id, forged := range s.forged {
+		if forged && s.behaviours[id] == Honest && !s.down(id) {
+			count++
+		}
+	}
+	return count
+}
+
+// corrupted returns how many nodes misbehave.
+func (s *Simulation) corrupted() int {
+	var count int
+	for _, behaviour := range s.behaviours {
+		if behaviour != Honest {
+			count++
+		}
+	}
+	return count
+}
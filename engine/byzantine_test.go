@@ -0,0 +1,15 @@
+package engine
+
+import "testing"
+
+func TestForgersNeedARumour(t *testing.T) {
+	status := finish(t, nil, WithSize(60, 30), WithNodes(150), WithSpread(2), WithSeed(3), WithProtocol("scuttlebutt"), WithByzantine(0.1, Forging))
+	if status.Informed != 0 || status.Misled != 0 {
+		t.Errorf("scuttlebutt with forgers: %d informed, %d misled, want none", status.Informed, status.Misled)
+	}
+
+	status = finish(t, nil, WithSize(60, 30), WithNodes(150), WithSpread(2), WithSeed(3), WithProtocol("push-pull"), WithByzantine(0.1, Forging))
+	if status.Misled == 0 {
+		t.Error("push-pull with forgers: no honest node holds the forged value")
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+// WithChurn has join nodes join and leave nodes leave the network every
+// round on average, while the simulation runs. A fractional rate adds one
+// more node with that probability, so a rate of 0.5 means a node every other
+// round. Joining nodes appear at random free positions holding no rumour,
+// and have to catch up through the protocol. Leaving nodes are picked at
+// random, and take the rumours they hold with them.
+func WithChurn(join, leave float64) Option {
+	return func(s *Simulation) {
+		s.joinRate = join
+		s.leaveRate = leave
+	}
+}
+
+// Joiner is implemented by protocols that keep state for every node, to be
+// told about nodes that join while the simulation runs.
+type Joiner interface {
+	Join(s *Simulation, id int)
+}
+
+// churn makes this round's nodes leave, then join.
+func (s *Simulation) churn() {
+	for range s.churned(s.leaveRate) {
+		if len(s.present) == 0 {
+			break
+		}
+		s.leave(s.present[s.rand.Intn(len(s.present))])
+	}
+	for range s.churned(s.joinRate) {
+		if len(s.nodeMap) >= s.width*s.height {
+			break
+		}
+		s.join()
+	}
+}
+
+// churned returns how many nodes rate moves this round.
+func (s *Simulation) churned(rate float64) int {
+	if rate <= 0 {
+		return 0
+	}
+	count := int(rate)
+	if s.rand.Float64() < rate-float64(count) {
+		count++
+	}
+	return count
+}
+
+// join adds an uninformed node at a random free position.
+func (s *Simulation) join() {
+	pixel := [2]int{s.rand.Intn(s.width), s.rand.Intn(s.height)}
+	for _, taken := s.nodeMap[pixel]; taken; _, taken = s.nodeMap[pixel] {
+		pixel = [2]int{s.rand.Intn(s.width), s.rand.Intn(s.height)}
+	}
+
+	id := len(s.nodes)
+	s.nodes = append(s.nodes, Node{X: pixel[0], Y: pixel[1]})
+	s.nodeMap[pixel] = id
+	s.states = append(s.states, Susceptible)
+	s.counts[Susceptible]++
+	s.informedAt = append(s.informedAt, -1)
+	s.via = append(s.via, Started)
+	s.heardAt = append(s.heardAt, -1)
+	s.held = append(s.held, 0)
+	s.fresh = append(s.fresh, 0)
+	s.behaviours = append(s.behaviours, Honest)
+	s.forged = append(s.forged, false)
+	s.position = append(s.position, len(s.present))
+	s.present = append(s.present, id)
+	s.missing++
+	s.joined++
+	s.joinTopology(id)
+
+	if joiner, ok := s.protocol.(Joiner); ok {
+		joiner.Join(s, id)
+	}
+	s.changes = append(s.changes, Change{Coord: pixel, State: Susceptible})
+}
+
+// leave takes id out of the network, freeing its position.
+func (s *Simulation) leave(id int) {
+	if s.states[id] != Crashed {
+		s.forget(id)
+	}
+	s.setState(id, Departed)
+	delete(s.nodeMap, s.coord(id))
+
+	last := s.present[len(s.present)-1]
+	s.present[s.position[id]] = last
+	s.position[last] = s.position[id]
+	s.present = s.present[:len(s.present)-1]
+	s.position[id] = -1
+	s.left++
+	s.regraphed = s.graph != nil
+
+	s.changes = append(s.changes, Change{Coord: s.coord(id), State: Departed})
+}
+
+// down reports whether id has crashed or left, and so takes no part in
+// the simulation.
+func (s *Simulation) down(id int) bool {
+	return s.states[id] == Crashed || s.states[id] == Departed
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// control paces a running simulation. Its methods are safe to call from
+// another goroutine while Run is in progress.
+type control struct {
+	mu     sync.Mutex
+	paused bool
+	steps  int           // rounds to run while paused
+	speed  float64       // rounds per second, 0 to run as fast as possible
+	wake   chan struct{} // signalled whenever the fields above change
+}
+
+func (c *control) update(f func()) {
+	c.mu.Lock()
+	f()
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pause stops the simulation before its next round.
+func (s *Simulation) Pause() {
+	s.control.update(func() { s.control.paused = true })
+}
+
+// Resume continues a paused simulation.
+func (s *Simulation) Resume() {
+	s.control.update(func() {
+		s.control.paused = false
+		s.control.steps = 0
+	})
+}
+
+// Step pauses the simulation if it is running, and lets it run one more
+// round.
+func (s *Simulation) Step() {
+	s.control.update(func() {
+		s.control.paused = true
+		s.control.steps++
+	})
+}
+
+// Paused reports whether the simulation is paused.
+func (s *Simulation) Paused() bool {
+	s.control.mu.Lock()
+	defer s.control.mu.Unlock()
+	return s.control.paused
+}
+
+// SetSpeed limits the simulation to roundsPerSecond rounds per second. A
+// speed of 0 or less runs rounds as fast as possible.
+func (s *Simulation) SetSpeed(roundsPerSecond float64) {
+	s.control.update(func() { s.control.speed = roundsPerSecond })
+}
+
+// wait blocks until the next round may start: immediately if running at
+// full speed, once a round's worth of time has passed since last, or, while
+// paused, once a step is taken or the simulation is resumed. While idle,
+// rounds run no faster than one every idleInterval unless stepped. It
+// returns early with ctx's error if ctx is cancelled.
+func (s *Simulation) wait(ctx context.Context, last time.Time) error {
+	for {
+		s.control.mu.Lock()
+		paused, speed := s.control.paused, s.control.speed
+		stepped := false
+		if paused && s.control.steps > 0 {
+			s.control.steps--
+			paused = false
+			stepped = true
+		}
+		s.control.mu.Unlock()
+
+		if paused {
+			select {
+			case <-s.control.wake:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		var interval time.Duration
+		if speed > 0 {
+			interval = time.Duration(float64(time.Second) / speed)
+		}
+		if s.idle > 0 {
+			interval = max(interval, idleInterval)
+		}
+		if stepped || interval <= 0 {
+			return ctx.Err()
+		}
+
+		delay := time.Until(last.Add(interval))
+		if delay <= 0 {
+			return ctx.Err()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			return nil
+		case <-s.control.wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// pace blocks until fraction of a round's worth of time has passed since
+// last, so that events within a round are spread out when the simulation
+// has a speed limit. It does nothing while running at full speed or paused.
+func (s *Simulation) pace(ctx context.Context, last time.Time, fraction float64) error {
+	s.control.mu.Lock()
+	paused, speed := s.control.paused, s.control.speed
+	s.control.mu.Unlock()
+
+	if paused || speed <= 0 {
+		return ctx.Err()
+	}
+
+	delay := time.Until(last.Add(time.Duration(fraction * float64(time.Second) / speed)))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
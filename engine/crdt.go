@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ConvergenceMsg is sent at the end of every round while a CRDT protocol
+// runs, and carries how far the nodes are from agreeing on its value.
+type ConvergenceMsg struct {
+	RunID       int
+	Round       int
+	Value       string          // the value every live node converges on, merging all of their states
+	Converged   int             // live nodes holding that value
+	Nodes       int             // live nodes
+	Updates     int             // updates applied
+	LastUpdate  int             // round the last update was applied in, -1 if none was
+	ConvergedIn int             // rounds it took to converge after the last update, -1 if it has not
+	Matches     map[[2]int]bool // live nodes that started or stopped holding the converged value
+}
+
+// updatable is implemented by protocols that nodes can apply local updates
+// to while the simulation runs.
+type updatable interface {
+	Protocol
+	update(s *Simulation, id int, undo bool)
+	convergence(s *Simulation) ConvergenceMsg
+}
+
+// pendingUpdates holds the updates applied by hand. Applying one is safe
+// from another goroutine while Run is in progress.
+type pendingUpdates struct {
+	mu      sync.Mutex
+	pending []pendingUpdate // applied since the last round started
+}
+
+type pendingUpdate struct {
+	at   [2]int
+	undo bool
+}
+
+// Updatable reports whether local updates can be applied to the nodes with
+// Update.
+func (s *Simulation) Updatable() bool {
+	_, ok := s.protocol.(updatable)
+	return ok
+}
+
+// Update applies a local update at the node at x, y, if there is one, at the
+// start of the next round: it increments a counter or adds an element to a
+// set, or with undo set decrements the counter or removes an element the
+// node holds. A grow-only counter ignores undo. It is safe to call while Run
+// is in progress.
+func (s *Simulation) Update(x, y int, undo bool) {
+	s.updates.mu.Lock()
+	defer s.updates.mu.Unlock()
+	s.updates.pending = append(s.updates.pending, pendingUpdate{at: [2]int{x, y}, undo: undo})
+}
+
+// update applies the updates made since the last round started at the nodes
+// that are still up.
+func (s *Simulation) update() {
+	s.updates.mu.Lock()
+	pending := s.updates.pending
+	s.updates.pending = nil
+	s.updates.mu.Unlock()
+
+	u, ok := s.protocol.(updatable)
+	if !ok {
+		return
+	}
+	for _, p := range pending {
+		if id, ok := s.nodeMap[p.at]; ok && !s.down(id) {
+			u.update(s, id, p.undo)
+		}
+	}
+}
+
+// crdtKind is the kind of replicated data type a crdt protocol holds.
+type crdtKind int
+
+const (
+	gCounter crdtKind = iota
+	pnCounter
+	orSet
+)
+
+// elements are what nodes add to an OR-Set.
+var elements = []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+// replica is the state of a CRDT held by a node.
+type replica interface {
+	// apply makes a local update at id.
+	apply(s *Simulation, id int, undo bool)
+	// merge merges other into the replica.
+	merge(other replica)
+	value() string
+}
+
+// crdt holds a conflict-free replicated data type at every node, after
+// Shapiro et al., "Conflict-free Replicated Data Types". Nodes apply local
+// updates to their own replica, and every round each live node exchanges
+// its whole state with spread random peers, both merging what they receive,
+// so that every node converges on the same value however the updates
+// interleave.
+//
+// No rumour is spread: the simulation needs no starting node, and since an
+// update can come at any time, it runs until it is cancelled. Rounds slow
+// down while the nodes agree, so that waiting for one costs little.
+type crdt struct {
+	kind        crdtKind
+	replicas    []replica
+	matched     []bool // whether each node held the converged value, as last reported
+	behind      int    // live nodes not holding it, as last reported
+	updates     int
+	lastUpdate  int
+	convergedIn int
+}
+
+func newCRDT(kind crdtKind) func() Protocol {
+	return func() Protocol {
+		return &crdt{kind: kind, lastUpdate: -1, convergedIn: -1}
+	}
+}
+
+func (c *crdt) rumourless() {}
+
+func (c *crdt) Targets(s *Simulation, id int) []int {
+	c.grow(s)
+	return s.randomPeers(id, s.spread)
+}
+
+// Receive merges from's state into to's, and to's reply into from's.
+func (c *crdt) Receive(s *Simulation, from, to int) {
+	if s.down(to) {
+		return
+	}
+	c.replicas[to].merge(c.replicas[from])
+	if s.send(to, from) {
+		c.replicas[from].merge(c.replicas[to])
+	}
+}
+
+func (c *crdt) Done(s *Simulation) bool {
+	return s.spread <= 0
+}
+
+func (c *crdt) lagging(s *Simulation) int {
+	return c.behind
+}
+
+func (c *crdt) Join(s *Simulation, id int) {
+	if c.replicas != nil {
+		c.grow(s)
+	}
+}
+
+// grow gives every node that does not have one an empty replica.
+func (c *crdt) grow(s *Simulation) {
+	for len(c.replicas) < len(s.nodes) {
+		c.replicas = append(c.replicas, c.empty())
+		c.matched = append(c.matched, false)
+	}
+}
+
+func (c *crdt) empty() replica {
+	switch c.kind {
+	case pnCounter:
+		return &pnReplica{p: gReplica{}, n: gReplica{}}
+	case orSet:
+		return &orReplica{adds: make(map[tag]string), removed: make(map[tag]bool)}
+	}
+	return gReplica{}
+}
+
+func (c *crdt) update(s *Simulation, id int, undo bool) {
+	c.grow(s)
+	c.replicas[id].apply(s, id, undo)
+	c.updates++
+	c.lastUpdate = s.Round()
+	c.convergedIn = -1
+}
+
+// convergence merges the state of every live node to find the value they
+// converge on, and reports which of them hold it.
+func (c *crdt) convergence(s *Simulation) ConvergenceMsg {
+	c.grow(s)
+	merged := c.empty()
+	for id, r := range c.replicas {
+		if !s.down(id) {
+			merged.merge(r)
+		}
+	}
+
+	msg := ConvergenceMsg{
+		Value:      merged.value(),
+		Updates:    c.updates,
+		LastUpdate: c.lastUpdate,
+		Matches:    make(map[[2]int]bool),
+	}
+	for id, r := range c.replicas {
+		if s.down(id) {
+			continue
+		}
+		msg.Nodes++
+		matched := r.value() == msg.Value
+		if matched {
+			msg.Converged++
+		}
+		if matched != c.matched[id] || s.Round() <= 1 {
+			c.matched[id] = matched
+			msg.Matches[s.coord(id)] = matched
+		}
+	}
+
+	if msg.Converged == msg.Nodes && c.lastUpdate >= 0 && c.convergedIn < 0 {
+		c.convergedIn = s.Round() - c.lastUpdate
+	}
+	msg.ConvergedIn = c.convergedIn
+	c.behind = msg.Nodes - msg.Converged
+	return msg
+}
+
+// gReplica is a grow-only counter: a count of increments by each node, whose
+// value is their sum. Merging takes the highest count for each node.
+type gReplica map[int]int
+
+func (g gReplica) apply(s *Simulation, id int, undo bool) {
+	g[id]++
+}
+
+func (g gReplica) merge(other replica) {
+	for id, count := range other.(gReplica) {
+		g[id] = max(g[id], count)
+	}
+}
+
+func (g gReplica) sum() int {
+	var total int
+	for _, count := range g {
+		total += count
+	}
+	return total
+}
+
+func (g gReplica) value() string {
+	return fmt.Sprint(g.sum())
+}
+
+// pnReplica is a counter that can also be decremented, made of one
+// grow-only counter of increments and one of decrements.
+type pnReplica struct {
+	p, n gReplica
+}
+
+func (r *pnReplica) apply(s *Simulation, id int, undo bool) {
+	if undo {
+		r.n.apply(s, id, false)
+		return
+	}
+	r.p.apply(s, id, false)
+}
+
+func (r *pnReplica) merge(other replica) {
+	o := other.(*pnReplica)
+	r.p.merge(o.p)
+	r.n.merge(o.n)
+}
+
+func (r *pnReplica) value() string {
+	return fmt.Sprint(r.p.sum() - r.n.sum())
+}
+
+// tag identifies a single add to an OR-Set: the node that made it, and how
+// many adds it had made before.
+type tag struct {
+	node, seq int
+}
+
+// orReplica is an observed-remove set. Every add is tagged uniquely, and a
+// remove only removes the adds of the element the node has observed, so an
+// add concurrent with a remove wins.
+type orReplica struct {
+	adds    map[tag]string
+	removed map[tag]bool
+	seq     int
+}
+
+// apply adds a random element or, with undo set, removes a random element the
+// replica holds.
+func (r *orReplica) apply(s *Simulation, id int, undo bool) {
+	if !undo {
+		r.adds[tag{id, r.seq}] = elements[s.rand.Intn(len(elements))]
+		r.seq++
+		return
+	}
+
+	held := r.elements()
+	if len(held) == 0 {
+		return
+	}
+	element := held[s.rand.Intn(len(held))]
+	for t, e := range r.adds {
+		if e == element {
+			r.removed[t] = true
+		}
+	}
+}
+
+func (r *orReplica) merge(other replica) {
+	o := other.(*orReplica)
+	maps.Copy(r.adds, o.adds)
+	maps.Copy(r.removed, o.removed)
+}
+
+// elements returns the elements in the set, in order.
+func (r *orReplica) elements() []string {
+	var held []string
+	for t, e := range r.adds {
+		if !r.removed[t] && !slices.Contains(held, e) {
+			held = append(held, e)
+		}
+	}
+	slices.Sort(held)
+	return held
+}
+
+func (r *orReplica) value() string {
+	return "{" + strings.Join(r.elements(), " ") + "}"
+}
@@ -0,0 +1,90 @@
+package engine
+
+import "sync"
+
+// WithCrashes crashes count random nodes at the start of round. Crashed
+// nodes never relay, and anything sent to them is lost. A round of 0 or
+// less crashes them as soon as the nodes are laid out, before a starting
+// node is picked.
+func WithCrashes(count, round int) Option {
+	return func(s *Simulation) {
+		s.crashes = count
+		s.crashRound = round
+	}
+}
+
+// WithLoss drops every send with probability loss, between 0 and 1.
+func WithLoss(loss float64) Option {
+	return func(s *Simulation) {
+		s.loss = loss
+	}
+}
+
+// kills holds the nodes killed by hand. Killing one is safe from another
+// goroutine while Run is in progress.
+type kills struct {
+	mu      sync.Mutex
+	pending [][2]int // positions killed since the last round started
+}
+
+// Kill crashes the node at x, y, if there is one, at the start of the next
+// round. It is safe to call while Run is in progress.
+func (s *Simulation) Kill(x, y int) {
+	s.kills.mu.Lock()
+	defer s.kills.mu.Unlock()
+	s.kills.pending = append(s.kills.pending, [2]int{x, y})
+}
+
+// kill crashes the nodes killed since the last round started that are still
+// up.
+func (s *Simulation) kill() {
+	s.kills.mu.Lock()
+	pending := s.kills.pending
+	s.kills.pending = nil
+	s.kills.mu.Unlock()
+
+	for _, pixel := range pending {
+		id, ok := s.nodeMap[pixel]
+		if !ok || s.down(id) {
+			continue
+		}
+		s.forget(id)
+		s.setState(id, Crashed)
+		s.changes = append(s.changes, Change{Coord: pixel, State: Crashed})
+	}
+}
+
+// send counts a send from from to to and reports whether the network
+// delivers it.
+func (s *Simulation) send(from, to int) bool {
+	s.messages++
+	if s.cut(from, to) {
+		s.partitioned++
+		s.dropped = append(s.dropped, s.coord(to))
+		return false
+	}
+	if s.loss > 0 && s.rand.Float64() < s.loss {
+		s.lost++
+		s.dropped = append(s.dropped, s.coord(to))
+		return false
+	}
+	return true
+}
+
+// crash crashes the configured number of nodes that are still up, picked at
+// random.
+func (s *Simulation) crash() {
+	var up []int
+	for id := range s.nodes {
+		if !s.down(id) {
+			up = append(up, id)
+		}
+	}
+	s.rand.Shuffle(len(up), func(i, j int) { up[i], up[j] = up[j], up[i] })
+
+	for _, id := range up[:min(s.crashes, len(up))] {
+		s.forget(id)
+		s.setState(id, Crashed)
+		s.changes = append(s.changes, Change{Coord: s.coord(id), State: Crashed})
+	}
+}
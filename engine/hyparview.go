@@ -0,0 +1,412 @@
+package engine
+
+import "slices"
+
+// Edge is a link between the nodes at From and To.
+type Edge struct {
+	From, To [2]int
+	Tree     bool // the link is part of a broadcast tree, eagerly pushing messages
+}
+
+// OverlayMsg is sent while a simulation is running whenever the links of an
+// overlay protocol change, and carries every link between live nodes.
+type OverlayMsg struct {
+	RunID int
+	Round int
+	Edges []Edge
+}
+
+// overlay is implemented by protocols that only gossip over the links of an
+// overlay they maintain, rather than with any node.
+type overlay interface {
+	Protocol
+	// build lays out the overlay, once the nodes are placed.
+	build(s *Simulation)
+	// edges returns the links of the overlay, and whether they changed since
+	// it was last called.
+	edges(s *Simulation) ([]Edge, bool)
+}
+
+// Overlay returns the links of the overlay the protocol gossips over, or nil
+// if it can reach any node. It is not safe to call while Run is in progress.
+func (s *Simulation) Overlay() []Edge {
+	o, ok := s.protocol.(overlay)
+	if !ok {
+		return nil
+	}
+	edges, _ := o.edges(s)
+	return edges
+}
+
+// random walk lengths and shuffle sizes, as suggested in the HyParView
+// paper.
+const (
+	activeWalk     = 6 // hops a join is forwarded before it joins the active view
+	passiveWalk    = 3 // hops left when a join is added to the passive view
+	shuffleActive  = 3 // active peers sent in a shuffle
+	shufflePassive = 4 // passive peers sent in a shuffle
+	shufflePeriod  = 5 // rounds between shuffles of each node
+)
+
+// hyparview maintains a partial view of the network at every node, after
+// Leitão et al., "HyParView: a Membership Protocol for Reliable
+// Gossip-Based Broadcast". Each node knows a small symmetric active view of
+// peers it gossips with, one more than the spread, and a larger passive view
+// of six times as many it can replace them from. Nodes join by sending a join to a random
+// contact, which random walks it through the overlay, and the passive views
+// are kept fresh by periodic shuffles along random walks. A node notices a
+// failed or cut off active peer the next round, as if its connection broke,
+// and repairs its active view with a neighbour request to a passive peer. A
+// peer dropped to make room for another sends a neighbour request straight
+// away, to the node that took its place.
+//
+// The rumour is flooded over the active links: every node passes it on to
+// each active peer it has not passed it to yet, so peers gained during a
+// repair are told as well.
+type hyparview struct {
+	active, passive [][]int
+	sent            []map[int]uint64 // rumours each node has passed to each peer
+	changed         bool             // active views changed since edges was last called
+	tree            bool             // links are part of a broadcast tree unless they are lazy
+	lazy            map[[2]int]bool  // links a node only announces over, by the node and its peer
+	round           int              // round last maintained
+}
+
+func newHyparview() Protocol {
+	return &hyparview{}
+}
+
+func (h *hyparview) Targets(s *Simulation, id int) []int {
+	if h.round != s.Round() {
+		h.maintain(s)
+	}
+
+	if !s.knew(id) {
+		return nil
+	}
+	rumours := s.passable(id)
+	var targets []int
+	for _, peer := range h.active[id] {
+		if rumours&^h.sent[id][peer] != 0 {
+			h.sent[id][peer] |= rumours
+			targets = append(targets, peer)
+		}
+	}
+	return targets
+}
+
+func (h *hyparview) Receive(s *Simulation, from, to int) {
+	s.Inform(to, from, Pushed)
+	h.sent[to][from] |= s.passable(from) // no need to pass them back
+}
+
+// Done reports true once every node that holds a rumour has passed it to
+// each of its active peers, and no live node is left without active peers
+// while it still has passive peers to repair its view from.
+func (h *hyparview) Done(s *Simulation) bool {
+	if s.Uninformed() == 0 {
+		return true
+	}
+	for id, peers := range h.active {
+		if s.down(id) {
+			continue
+		}
+		if len(peers) == 0 && len(h.passive[id]) > 0 {
+			return false
+		}
+		if !s.relays(id) {
+			continue
+		}
+		for _, peer := range peers {
+			if s.held[id]&^h.sent[id][peer] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (h *hyparview) Join(s *Simulation, id int) {
+	h.add()
+	h.know(s, id)
+	if contact, ok := h.contact(s, id); ok {
+		h.join(s, id, contact)
+	}
+}
+
+func (h *hyparview) add() {
+	h.active = append(h.active, nil)
+	h.passive = append(h.passive, nil)
+	h.sent = append(h.sent, make(map[int]uint64))
+}
+
+// build lays out the overlay by joining the nodes one after the other, each
+// through a random node that joined before it, or under a topology through
+// a random node it is linked to.
+func (h *hyparview) build(s *Simulation) {
+	for id := range s.nodes {
+		h.add()
+		if s.graph == nil && id > 0 {
+			h.join(s, id, s.rand.Intn(id))
+		}
+	}
+	if s.graph != nil {
+		for id := range s.nodes {
+			h.know(s, id)
+			if contact, ok := h.contact(s, id); ok {
+				h.join(s, id, contact)
+			}
+		}
+	}
+	h.changed = true
+}
+
+// know puts the nodes the topology links id to in its passive view, as the
+// only peers it can ever make active.
+func (h *hyparview) know(s *Simulation, id int) {
+	if s.graph == nil {
+		return
+	}
+	for _, peer := range s.neighbours(id) {
+		h.addPassive(s, id, peer)
+	}
+}
+
+// maintain runs once at the start of every round: live nodes drop the
+// active peers that failed, repair their active views and shuffle when due.
+func (h *hyparview) maintain(s *Simulation) {
+	h.round = s.Round()
+	for id := range h.active {
+		if s.down(id) {
+			continue
+		}
+		h.repair(s, id)
+		if (h.round+id)%shufflePeriod == 0 {
+			h.shuffle(s, id)
+		}
+	}
+}
+
+// contact picks a random live node other than id to join through.
+func (h *hyparview) contact(s *Simulation, id int) (int, bool) {
+	for range 3 {
+		peers := s.randomPeers(id, 1)
+		if len(peers) == 0 {
+			return 0, false
+		}
+		if !s.down(peers[0]) {
+			return peers[0], true
+		}
+	}
+	return 0, false
+}
+
+// join adds id to the overlay through contact, which takes it into its
+// active view and forwards the join to each of its other active peers.
+func (h *hyparview) join(s *Simulation, id, contact int) {
+	h.link(s, id, contact)
+	for _, peer := range slices.Clone(h.active[contact]) {
+		if peer != id {
+			h.forwardJoin(s, peer, id, contact, activeWalk)
+		}
+	}
+}
+
+// forwardJoin carries the join of node one hop further on its random walk,
+// at at, having come from from.
+func (h *hyparview) forwardJoin(s *Simulation, at, node, from, ttl int) {
+	if s.down(at) || at == node {
+		return
+	}
+	if ttl == 0 || len(h.active[at]) <= 1 {
+		h.link(s, at, node)
+		return
+	}
+	if ttl == passiveWalk {
+		h.addPassive(s, at, node)
+	}
+	next, ok := h.randomActive(s, at, from, node)
+	if !ok {
+		h.link(s, at, node)
+		return
+	}
+	h.forwardJoin(s, next, node, at, ttl-1)
+}
+
+// repair drops the active peers of id that failed or were cut off, and
+// sends neighbour requests to passive peers until its active view is full
+// again or every passive peer was tried.
+func (h *hyparview) repair(s *Simulation, id int) {
+	for _, peer := range slices.Clone(h.active[id]) {
+		if s.down(peer) || s.cut(id, peer) {
+			h.unlink(id, peer)
+		}
+	}
+
+	candidates := slices.Clone(h.passive[id])
+	s.rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	for _, peer := range candidates {
+		if len(h.active[id]) >= h.activeSize(s) {
+			return
+		}
+		if s.down(peer) || s.cut(id, peer) {
+			h.passive[id] = remove(h.passive[id], peer)
+			continue
+		}
+		h.request(s, id, peer)
+	}
+}
+
+// request sends a neighbour request from id to peer, which accepts it if it
+// has room in its active view. A full peer still accepts it if id has room
+// for two more peers, since the peer it drops to make room then moves to id,
+// and the overlay stays connected.
+func (h *hyparview) request(s *Simulation, id, peer int) {
+	if s.cut(id, peer) {
+		return
+	}
+	if len(h.active[peer]) < h.activeSize(s) || len(h.active[id]) <= h.activeSize(s)-2 {
+		h.link(s, id, peer)
+	}
+}
+
+// shuffle sends id's own entry and a sample of its views on a random walk,
+// and swaps them for a sample of the passive view of the node the walk ends
+// at.
+func (h *hyparview) shuffle(s *Simulation, id int) {
+	at, ok := h.randomActive(s, id, -1, -1)
+	if !ok {
+		return
+	}
+	from := id
+	for ttl := passiveWalk - 1; ttl > 0; ttl-- {
+		next, ok := h.randomActive(s, at, from, id)
+		if !ok {
+			break
+		}
+		from, at = at, next
+	}
+
+	sent := append([]int{id}, h.sample(s, h.active[id], shuffleActive)...)
+	sent = append(sent, h.sample(s, h.passive[id], shufflePassive)...)
+	reply := h.sample(s, h.passive[at], len(sent))
+	for _, peer := range sent {
+		h.addPassive(s, at, peer)
+	}
+	for _, peer := range reply {
+		h.addPassive(s, id, peer)
+	}
+}
+
+// link makes a and b active peers of each other, if the topology links
+// them. A peer either of them drops to make room sends a neighbour request
+// to the other, so that the overlay stays connected through it where it
+// can.
+func (h *hyparview) link(s *Simulation, a, b int) {
+	if !s.linked(a, b) {
+		return
+	}
+	droppedA, okA := h.addActive(s, a, b)
+	droppedB, okB := h.addActive(s, b, a)
+	if okA && !s.down(droppedA) {
+		h.request(s, droppedA, b)
+	}
+	if okB && !s.down(droppedB) {
+		h.request(s, droppedB, a)
+	}
+}
+
+// unlink drops b from the active view of a, and a from that of b.
+func (h *hyparview) unlink(a, b int) {
+	h.active[a] = remove(h.active[a], b)
+	h.active[b] = remove(h.active[b], a)
+	delete(h.lazy, [2]int{a, b})
+	delete(h.lazy, [2]int{b, a})
+	h.changed = true
+}
+
+// addActive adds peer to the active view of id, making room by moving a
+// random active peer, disconnected from id, to the passive view. It returns
+// the peer it dropped, if any.
+func (h *hyparview) addActive(s *Simulation, id, peer int) (dropped int, ok bool) {
+	if id == peer || slices.Contains(h.active[id], peer) {
+		return 0, false
+	}
+	if len(h.active[id]) >= h.activeSize(s) {
+		dropped, ok = h.active[id][s.rand.Intn(len(h.active[id]))], true
+		h.unlink(id, dropped)
+		h.addPassive(s, id, dropped)
+		h.addPassive(s, dropped, id)
+	}
+	h.passive[id] = remove(h.passive[id], peer)
+	h.active[id] = append(h.active[id], peer)
+	h.changed = true
+	return dropped, ok
+}
+
+// addPassive adds peer to the passive view of id, if it is in neither view
+// and the topology links them, evicting a random passive peer if it is full.
+func (h *hyparview) addPassive(s *Simulation, id, peer int) {
+	if id == peer || !s.linked(id, peer) || slices.Contains(h.active[id], peer) || slices.Contains(h.passive[id], peer) {
+		return
+	}
+	if len(h.passive[id]) >= 6*h.activeSize(s) {
+		h.passive[id] = slices.Delete(h.passive[id], 0, 1)
+	}
+	h.passive[id] = append(h.passive[id], peer)
+}
+
+// randomActive picks a random active peer of id that is neither of the
+// excluded nodes.
+func (h *hyparview) randomActive(s *Simulation, id, exclude, node int) (int, bool) {
+	peers := slices.DeleteFunc(slices.Clone(h.active[id]), func(peer int) bool {
+		return peer == exclude || peer == node || s.down(peer)
+	})
+	if len(peers) == 0 {
+		return 0, false
+	}
+	return peers[s.rand.Intn(len(peers))], true
+}
+
+// sample picks up to count of peers at random.
+func (h *hyparview) sample(s *Simulation, peers []int, count int) []int {
+	if count >= len(peers) {
+		return slices.Clone(peers)
+	}
+	picked := make([]int, count)
+	for i, j := range s.rand.Perm(len(peers))[:count] {
+		picked[i] = peers[j]
+	}
+	return picked
+}
+
+// activeSize is how many active peers each node keeps: one more than the
+// spread, but at least four, since smaller active views tend to split the
+// overlay.
+func (h *hyparview) activeSize(s *Simulation) int {
+	return max(s.spread, 3) + 1
+}
+
+func (h *hyparview) edges(s *Simulation) ([]Edge, bool) {
+	changed := h.changed
+	h.changed = false
+
+	var edges []Edge
+	for id, peers := range h.active {
+		if s.down(id) {
+			continue
+		}
+		for _, peer := range peers {
+			if !s.down(peer) && peer < id && slices.Contains(h.active[peer], id) {
+				continue // listed from the other end
+			}
+			tree := h.tree && !h.lazy[[2]int{id, peer}] && !h.lazy[[2]int{peer, id}]
+			edges = append(edges, Edge{From: s.coord(id), To: s.coord(peer), Tree: tree})
+		}
+	}
+	return edges, changed
+}
+
+func remove(peers []int, peer int) []int {
+	return slices.DeleteFunc(peers, func(p int) bool { return p == peer })
+}
@@ -0,0 +1,16 @@
+package engine
+
+import "testing"
+
+// Without faults, the overlay must stay connected however the joins evict
+// each other, so flooding over it reaches every node.
+func TestHyparviewInformsEveryNode(t *testing.T) {
+	for _, nodes := range []int{400, 800, 1500} {
+		for seed := int64(1); seed <= 5; seed++ {
+			status := finish(t, nil, WithSize(400, 100), WithNodes(nodes), WithSpread(2), WithSeed(seed), WithProtocol("hyparview"))
+			if status.Informed != status.Nodes {
+				t.Errorf("%d nodes, seed %d: %d of %d nodes informed, want all", nodes, seed, status.Informed, status.Nodes)
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+)
+
+// Partition splits the network along a line, or around a rectangle. Sends
+// between nodes on opposite sides of it are dropped.
+type Partition struct {
+	From, To [2]int // the ends of the line, or opposite corners of the rectangle
+	Rect     bool
+	HealAt   int // round the partition heals in, 0 if it never does
+}
+
+// PartitionMsg is sent while a simulation is running whenever a partition
+// takes effect or heals, and carries every partition still in place.
+type PartitionMsg struct {
+	RunID      int
+	Round      int
+	Partitions []Partition
+}
+
+// partitions holds the partitions in place. Adding one is safe from another
+// goroutine while Run is in progress.
+type partitions struct {
+	mu      sync.Mutex
+	pending []pendingPartition // added since the last round started
+	active  []Partition
+}
+
+type pendingPartition struct {
+	Partition
+	healAfter int
+}
+
+// AddPartition splits the network along the line from from to to, or around
+// the rectangle with those opposite corners if rect is set. It takes effect
+// at the start of the next round and, if healAfter is above 0, heals once it
+// has been in place for that many rounds. It is safe to call while Run is in
+// progress.
+func (s *Simulation) AddPartition(from, to [2]int, rect bool, healAfter int) {
+	s.partitions.mu.Lock()
+	defer s.partitions.mu.Unlock()
+	s.partitions.pending = append(s.partitions.pending, pendingPartition{
+		Partition: Partition{From: from, To: to, Rect: rect},
+		healAfter: healAfter,
+	})
+}
+
+// partition puts the partitions added since the last round in place and
+// heals those that are due, telling p if anything changed.
+func (s *Simulation) partition(p Sender) {
+	s.partitions.mu.Lock()
+	defer s.partitions.mu.Unlock()
+
+	changed := len(s.partitions.pending) > 0
+	for _, pending := range s.partitions.pending {
+		if pending.healAfter > 0 {
+			pending.HealAt = s.round + pending.healAfter
+		}
+		s.partitions.active = append(s.partitions.active, pending.Partition)
+	}
+	s.partitions.pending = nil
+
+	before := len(s.partitions.active)
+	s.partitions.active = slices.DeleteFunc(s.partitions.active, func(p Partition) bool {
+		return p.HealAt > 0 && p.HealAt <= s.round
+	})
+	changed = changed || len(s.partitions.active) != before
+
+	if changed {
+		p.Send(PartitionMsg{RunID: s.runID, Round: s.round, Partitions: append([]Partition(nil), s.partitions.active...)})
+	}
+}
+
+// cut reports whether a partition in place separates from and to, or the
+// topology does not link them.
+func (s *Simulation) cut(from, to int) bool {
+	if !s.linked(from, to) {
+		return true
+	}
+
+	s.partitions.mu.Lock()
+	defer s.partitions.mu.Unlock()
+
+	a, b := s.coord(from), s.coord(to)
+	for _, p := range s.partitions.active {
+		if p.separates(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// healing reports whether a partition is waiting to take effect or due to
+// heal, either of which may let the rumour move again.
+func (s *Simulation) healing() bool {
+	s.partitions.mu.Lock()
+	defer s.partitions.mu.Unlock()
+
+	if len(s.partitions.pending) > 0 {
+		return true
+	}
+	for _, p := range s.partitions.active {
+		if p.HealAt > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// separates reports whether a and b are on opposite sides of p. Points on a
+// rectangle's edge are inside it, and points on a line count as being on
+// one side of it, so that no node can bridge the partition.
+func (p Partition) separates(a, b [2]int) bool {
+	if p.Rect {
+		return p.contains(a) != p.contains(b)
+	}
+	sideA := orientation(p.From, p.To, a) >= 0
+	sideB := orientation(p.From, p.To, b) >= 0
+	return sideA != sideB && orientation(a, b, p.From)*orientation(a, b, p.To) <= 0
+}
+
+func (p Partition) contains(point [2]int) bool {
+	return point[0] >= min(p.From[0], p.To[0]) && point[0] <= max(p.From[0], p.To[0]) &&
+		point[1] >= min(p.From[1], p.To[1]) && point[1] <= max(p.From[1], p.To[1])
+}
+
+// orientation returns the sign of the turn from a to b to c: 1 for one
+// direction, -1 for the other and 0 if they are on one line.
+func orientation(a, b, c [2]int) int {
+	cross := (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+	switch {
+	case cross > 0:
+		return 1
+	case cross < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionSeparates(t *testing.T) {
+	line := Partition{From: [2]int{10, 0}, To: [2]int{10, 10}}
+	rect := Partition{From: [2]int{10, 10}, To: [2]int{20, 20}, Rect: true}
+	tests := []struct {
+		name string
+		p    Partition
+		a, b [2]int
+		want bool
+	}{
+		{"across a line", line, [2]int{5, 5}, [2]int{15, 5}, true},
+		{"same side of a line", line, [2]int{5, 5}, [2]int{8, 2}, false},
+		{"past the end of a line", line, [2]int{5, 20}, [2]int{15, 20}, false},
+		{"on a line, with one side", line, [2]int{10, 5}, [2]int{5, 5}, false},
+		{"on a line, from the other side", line, [2]int{10, 5}, [2]int{15, 5}, true},
+		{"into a rectangle", rect, [2]int{15, 15}, [2]int{5, 5}, true},
+		{"both inside a rectangle", rect, [2]int{12, 18}, [2]int{19, 11}, false},
+		{"on a rectangle's edge", rect, [2]int{10, 15}, [2]int{15, 15}, false},
+		{"out from a rectangle's edge", rect, [2]int{10, 15}, [2]int{9, 15}, true},
+		{"both outside a rectangle", rect, [2]int{5, 15}, [2]int{25, 15}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.p.separates(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: separates(%v, %v) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+		if got := tt.p.separates(tt.b, tt.a); got != tt.want {
+			t.Errorf("%s: separates(%v, %v) = %v, want %v", tt.name, tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// A partition that never heals keeps some nodes from the rumour for good, so
+// the run has to end once it stops making progress, even while sends are in
+// flight.
+func TestPartitionNeverHealingEnds(t *testing.T) {
+	cut := func(s *Simulation) { s.AddPartition([2]int{20, 0}, [2]int{20, 29}, false, 0) }
+	for _, protocol := range []string{"nearest push", "random push", "pull", "push-pull"} {
+		for _, latency := range []time.Duration{0, 10 * time.Millisecond} {
+			status := finish(t, cut, WithSize(60, 30), WithNodes(150), WithSpread(2), WithSeed(3),
+				WithProtocol(protocol), WithLatency(latency, 0))
+			if status.Informed == 0 || status.Informed >= status.Nodes {
+				t.Errorf("%s, latency %v: informed %d of %d nodes, want some but not all", protocol, latency, status.Informed, status.Nodes)
+			}
+		}
+	}
+}
+
+func TestPartitionHealing(t *testing.T) {
+	cut := func(s *Simulation) { s.AddPartition([2]int{20, 0}, [2]int{20, 29}, false, 30) }
+	status := finish(t, cut, WithSize(60, 30), WithNodes(150), WithSpread(2), WithSeed(3), WithProtocol("push-pull"))
+	if status.Informed != status.Nodes {
+		t.Errorf("informed %d of %d nodes once the partition healed, want all", status.Informed, status.Nodes)
+	}
+}
@@ -0,0 +1,199 @@
+package engine
+
+// BroadcastMsg is sent at the end of every round while a tree-based
+// broadcast protocol runs, and counts its messages against flooding.
+type BroadcastMsg struct {
+	RunID    int
+	Round    int
+	Payload  int // messages carrying rumours, pushed eagerly or sent after a graft
+	Control  int // announcements, grafts and prunes
+	Flooding int // messages flooding the same rumours over the same overlay would have taken
+}
+
+// broadcaster is implemented by protocols that broadcast along a tree, to
+// be compared against flooding.
+type broadcaster interface {
+	Protocol
+	counts() BroadcastMsg
+}
+
+// graftTimeout is how many rounds a node waits for a rumour it was told
+// about before grafting the link it was told over.
+const graftTimeout = 1
+
+// announcement tells a node that from holds rumours it is missing.
+type announcement struct {
+	from    int
+	rumours uint64
+	round   int // round it was made in
+}
+
+// plumtree broadcasts over a HyParView overlay along a spanning tree, after
+// Leitão et al., "Epidemic Broadcast Trees". Every link starts out eager:
+// a node pushes the rumours it learns to its eager peers, other than the
+// one it learned them from, and only announces them to its lazy peers. A
+// node that is pushed rumours it already has prunes the link, and both ends
+// make it lazy, so the eager links settle into a tree. A node that is
+// announced rumours it still misses graftTimeout rounds later grafts the
+// link back into the tree and has them sent over it, which repairs the tree
+// when a node on it fails.
+type plumtree struct {
+	*hyparview
+	sender    []int    // peer each node last learned rumours from, -1 once it passed them on
+	relayed   []uint64 // rumours each node has passed on
+	announced [][]announcement
+	payload   int
+	control   int
+	flooding  int
+}
+
+func newPlumtree() Protocol {
+	return &plumtree{hyparview: &hyparview{tree: true, lazy: make(map[[2]int]bool)}}
+}
+
+func (p *plumtree) build(s *Simulation) {
+	p.hyparview.build(s)
+	for range s.nodes {
+		p.add()
+	}
+}
+
+func (p *plumtree) Join(s *Simulation, id int) {
+	p.hyparview.Join(s, id)
+	p.add()
+}
+
+func (p *plumtree) add() {
+	p.sender = append(p.sender, -1)
+	p.relayed = append(p.relayed, 0)
+	p.announced = append(p.announced, nil)
+}
+
+func (p *plumtree) Targets(s *Simulation, id int) []int {
+	if p.round != s.Round() {
+		p.maintain(s)
+		p.graft(s)
+	}
+
+	if !s.knew(id) {
+		return nil
+	}
+	news := s.passable(id) &^ p.relayed[id]
+	if news == 0 {
+		return nil
+	}
+	p.relayed[id] |= news
+	sender := p.sender[id]
+	p.sender[id] = -1
+
+	var targets []int
+	for _, peer := range p.active[id] {
+		if peer == sender {
+			continue
+		}
+		p.flooding++
+		if p.lazy[[2]int{id, peer}] {
+			p.announce(s, id, peer, news)
+			continue
+		}
+		targets = append(targets, peer)
+	}
+	p.payload += len(targets)
+	return targets
+}
+
+// Receive handles rumours pushed eagerly from from to to, pruning the link
+// if they were nothing new.
+func (p *plumtree) Receive(s *Simulation, from, to int) {
+	duplicate := s.relays(from) && !s.offers(from, to)
+	if s.Inform(to, from, Pushed) {
+		p.sender[to] = from
+		return
+	}
+	if duplicate && !s.down(to) {
+		p.prune(s, to, from)
+	}
+}
+
+// Done reports true once every node that holds a rumour has passed it on,
+// and no node is still waiting on an announcement.
+func (p *plumtree) Done(s *Simulation) bool {
+	if s.Uninformed() == 0 {
+		return true
+	}
+	for id := range p.active {
+		if s.down(id) || !s.relays(id) {
+			continue
+		}
+		if s.held[id]&^p.relayed[id] != 0 || len(p.announced[id]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// announce tells peer, over a lazy link, that id holds rumours.
+func (p *plumtree) announce(s *Simulation, id, peer int, rumours uint64) {
+	p.control++
+	if !s.send(id, peer) || s.down(peer) {
+		return
+	}
+	if missing := rumours &^ s.held[peer]; missing != 0 {
+		p.announced[peer] = append(p.announced[peer], announcement{from: id, rumours: missing, round: s.round})
+	}
+}
+
+// prune makes the link between id and peer lazy, once id was pushed rumours
+// by peer it already had.
+func (p *plumtree) prune(s *Simulation, id, peer int) {
+	p.lazy[[2]int{id, peer}] = true
+	p.changed = true
+	p.control++
+	if s.send(id, peer) && !s.down(peer) {
+		p.lazy[[2]int{peer, id}] = true
+	}
+}
+
+// graft has every live node that is still missing rumours it was announced
+// long enough ago graft the link to one of the nodes that announced them,
+// making it eager at both ends, and be sent the rumours over it. Each node
+// grafts at most once per round.
+func (p *plumtree) graft(s *Simulation) {
+	for id, announcements := range p.announced {
+		if s.down(id) {
+			p.announced[id] = nil
+			continue
+		}
+
+		var kept []announcement
+		grafted := false
+		for _, a := range announcements {
+			if a.rumours&^s.held[id] == 0 {
+				continue
+			}
+			if grafted || s.round-a.round <= graftTimeout {
+				kept = append(kept, a)
+				continue
+			}
+
+			p.control++
+			if s.down(a.from) || !s.send(id, a.from) {
+				continue
+			}
+			grafted = true
+			delete(p.lazy, [2]int{id, a.from})
+			delete(p.lazy, [2]int{a.from, id})
+			p.changed = true
+
+			p.payload++
+			if s.send(a.from, id) && s.Inform(id, a.from, Pulled) {
+				p.sender[id] = a.from
+			}
+		}
+		p.announced[id] = kept
+	}
+}
+
+func (p *plumtree) counts() BroadcastMsg {
+	return BroadcastMsg{Payload: p.payload, Control: p.control, Flooding: p.flooding}
+}
@@ -0,0 +1,22 @@
+package engine
+
+import "testing"
+
+// Without faults, the broadcast tree must reach every node over the overlay,
+// and pruning must save payload messages against flooding it.
+func TestPlumtreeBroadcast(t *testing.T) {
+	for _, nodes := range []int{400, 800, 1500} {
+		for seed := int64(1); seed <= 5; seed++ {
+			var s *Simulation
+			status := finish(t, func(sim *Simulation) { s = sim },
+				WithSize(400, 100), WithNodes(nodes), WithSpread(2), WithSeed(seed), WithProtocol("plumtree"))
+			if status.Informed != status.Nodes {
+				t.Errorf("%d nodes, seed %d: %d of %d nodes informed, want all", nodes, seed, status.Informed, status.Nodes)
+			}
+			counts := s.protocol.(broadcaster).counts()
+			if counts.Payload >= counts.Flooding {
+				t.Errorf("%d nodes, seed %d: %d payload messages, want fewer than the %d of flooding", nodes, seed, counts.Payload, counts.Flooding)
+			}
+		}
+	}
+}
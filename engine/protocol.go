@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Protocol decides how a rumour spreads. Every round the simulation asks it
+// which peers each node contacts, hands it each of those contacts, and stops
+// once it reports that the rumour is done spreading.
+type Protocol interface {
+	// Targets returns the peers that id contacts this round.
+	Targets(s *Simulation, id int) []int
+	// Receive handles a single contact made by from to to.
+	Receive(s *Simulation, from, to int)
+	// Done reports whether the simulation should stop.
+	Done(s *Simulation) bool
+}
+
+// rumourless is implemented by protocols that spread no rumour, such as
+// those that track which nodes are up or reconcile state. They need no
+// starting node, and are done when they say so.
+type rumourless interface {
+	Protocol
+	rumourless()
+}
+
+// membership is implemented by protocols that track which nodes are up.
+type membership interface {
+	Protocol
+	status(s *Simulation) MembershipMsg
+}
+
+// lagging is implemented by protocols that spread no rumour, and reports how
+// much the nodes have yet to catch up on, such as failures not yet detected,
+// so that a run that stops catching up can be ended, and one with nothing to
+// catch up on can idle.
+type lagging interface {
+	Protocol
+	lagging(s *Simulation) int
+}
+
+type protocol struct {
+	name string
+	new  func() Protocol
+}
+
+var protocols = []protocol{
+	{"nearest push", newNearestPush},
+	{"random push", newRandomPush},
+	{"pull", newPull},
+	{"push-pull", newPushPull},
+	{"rumour counter/feedback", newRumourMongering(counter, feedback)},
+	{"rumour counter/blind", newRumourMongering(counter, blind)},
+	{"rumour coin/feedback", newRumourMongering(coin, feedback)},
+	{"rumour coin/blind", newRumourMongering(coin, blind)},
+	{"anti-entropy only", newAntiEntropyOnly},
+	{"swim", newSwim},
+	{"hyparview", newHyparview},
+	{"plumtree", newPlumtree},
+	{"scuttlebutt", newScuttlebutt},
+	{"crdt g-counter", newCRDT(gCounter)},
+	{"crdt pn-counter", newCRDT(pnCounter)},
+	{"crdt or-set", newCRDT(orSet)},
+}
+
+// Register makes a protocol available under name, so it can be picked with
+// WithProtocol. It panics if name is already registered.
+func Register(name string, new func() Protocol) {
+	if slices.ContainsFunc(protocols, func(p protocol) bool { return p.name == name }) {
+		panic(fmt.Sprintf("engine: protocol %q registered twice", name))
+	}
+	protocols = append(protocols, protocol{name, new})
+}
+
+// Protocols returns the names of every registered protocol, starting with
+// the default one.
+func Protocols() []string {
+	names := make([]string, len(protocols))
+	for i, p := range protocols {
+		names[i] = p.name
+	}
+	return names
+}
+
+func newProtocol(name string) (Protocol, error) {
+	if name == "" {
+		return protocols[0].new(), nil
+	}
+	for _, p := range protocols {
+		if p.name == name {
+			return p.new(), nil
+		}
+	}
+	return nil, fmt.Errorf("engine: unknown protocol %q", name)
+}
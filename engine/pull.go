@@ -0,0 +1,53 @@
+package engine
+
+// pull has every node missing a rumour ask spread random peers for what they
+// know each round. A peer that already had a rumour the asker is missing
+// answers, and the asker is informed.
+type pull struct{}
+
+func newPull() Protocol {
+	return pull{}
+}
+
+func (pull) Targets(s *Simulation, id int) []int {
+	if s.Complete(id) {
+		return nil
+	}
+	return s.randomPeers(id, s.spread)
+}
+
+func (pull) Receive(s *Simulation, from, to int) {
+	if s.knew(to) {
+		s.Inform(from, to, Pulled)
+	}
+}
+
+func (pull) Done(s *Simulation) bool {
+	return s.Uninformed() == 0 || s.spread <= 0
+}
+
+// pushPull has every node contact spread random peers each round and
+// exchange what they know in both directions: an informed node pushes its
+// rumours to its peer, and pulls any it is missing from the peer.
+type pushPull struct{}
+
+func newPushPull() Protocol {
+	return pushPull{}
+}
+
+func (pushPull) Targets(s *Simulation, id int) []int {
+	return s.randomPeers(id, s.spread)
+}
+
+func (pushPull) Receive(s *Simulation, from, to int) {
+	if s.knew(from) {
+		s.Inform(to, from, Pushed)
+	}
+	if s.offers(to, from) {
+		s.Inform(from, to, Pulled)
+	}
+}
+
+func (pushPull) Done(s *Simulation) bool {
+	return s.Uninformed() == 0 || s.spread <= 0
+}
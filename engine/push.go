@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"cmp"
+	"slices"
+)
+
+// nearestPush is the original strategy: every node informed in the previous
+// round relays what it knows once, to the spread nearest nodes that are
+// missing any of it, and is then removed. The nodes still missing a rumour
+// are kept in a k-d tree, so the nearest are found without sorting every
+// node.
+type nearestPush struct {
+	remaining *kdTree // nodes not yet holding every rumour, dropped once found holding them
+}
+
+func newNearestPush() Protocol {
+	return &nearestPush{}
+}
+
+func (p *nearestPush) Targets(s *Simulation, id int) []int {
+	if s.State(id) != Infected || s.heardAt[id] != s.Round()-1 {
+		return nil
+	}
+	s.Remove(id)
+
+	if s.graph != nil {
+		return p.nearestNeighbours(s, id)
+	}
+
+	if p.remaining == nil {
+		p.remaining = newKDTree(s.nodes, s.present)
+	}
+	var done []int
+	targets := p.remaining.nearest(s.nodes, s.nodes[id], s.spread, func(other int) bool {
+		if s.Complete(other) || s.State(other) == Departed {
+			done = append(done, other)
+			return false
+		}
+		return other != id && s.offers(id, other)
+	})
+	for _, other := range done {
+		p.remaining.remove(other)
+	}
+	return targets
+}
+
+// nearestNeighbours returns the spread nodes nearest id, among those the
+// topology links it to, that are missing any of what it knows.
+func (p *nearestPush) nearestNeighbours(s *Simulation, id int) []int {
+	missing := slices.DeleteFunc(s.neighbours(id), func(other int) bool {
+		return !s.offers(id, other)
+	})
+	slices.SortFunc(missing, func(a, b int) int {
+		return cmp.Or(cmp.Compare(s.distance(id, a), s.distance(id, b)), cmp.Compare(a, b))
+	})
+	return missing[:min(s.spread, len(missing))]
+}
+
+func (p *nearestPush) Join(s *Simulation, id int) {
+	if p.remaining != nil {
+		p.remaining.add(s.nodes, id)
+	}
+}
+
+func (p *nearestPush) Receive(s *Simulation, from, to int) {
+	s.Inform(to, from, Pushed)
+}
+
+// Done reports true once everyone is informed, or nobody is left to relay.
+func (p *nearestPush) Done(s *Simulation) bool {
+	return s.Uninformed() == 0 || s.Count(Infected) == 0
+}
+
+// randomPush is classic epidemic push: every informed node contacts spread
+// peers picked uniformly at random each round, whether or not they already
+// have the rumour. Nodes never stop spreading, as in the SI model.
+type randomPush struct{}
+
+func newRandomPush() Protocol {
+	return randomPush{}
+}
+
+func (randomPush) Targets(s *Simulation, id int) []int {
+	if !s.knew(id) {
+		return nil
+	}
+	return s.randomPeers(id, s.spread)
+}
+
+func (randomPush) Receive(s *Simulation, from, to int) {
+	s.Inform(to, from, Pushed)
+}
+
+func (randomPush) Done(s *Simulation) bool {
+	return s.Uninformed() == 0 || s.spread <= 0
+}
@@ -0,0 +1,78 @@
+package engine
+
+// How a rumour mongering node decides to lose interest, following Demers et
+// al., "Epidemic Algorithms for Replicated Database Maintenance".
+type lossOfInterest int
+
+const (
+	counter lossOfInterest = iota // stop after k contacts
+	coin                          // stop with probability 1/k on each contact
+)
+
+// Which contacts count towards losing interest.
+type response int
+
+const (
+	feedback response = iota // only contacts with nodes that already knew
+	blind                    // every contact
+)
+
+// rumourMongering keeps every informed node infected, or hot, pushing the
+// rumour to spread random peers each round, until it loses interest and is
+// removed. The rumour dies
+// out once no node is hot, which can leave some nodes uninformed.
+type rumourMongering struct {
+	loss     lossOfInterest
+	response response
+	contacts []int // contacts that counted towards losing interest
+}
+
+func newRumourMongering(loss lossOfInterest, response response) func() Protocol {
+	return func() Protocol {
+		return &rumourMongering{loss: loss, response: response}
+	}
+}
+
+func (r *rumourMongering) Targets(s *Simulation, id int) []int {
+	if r.contacts == nil {
+		r.contacts = make([]int, len(s.nodes))
+	}
+	if !s.knew(id) || s.State(id) != Infected {
+		return nil
+	}
+	return s.randomPeers(id, s.spread)
+}
+
+func (r *rumourMongering) Join(s *Simulation, id int) {
+	if r.contacts != nil {
+		r.contacts = append(r.contacts, 0)
+	}
+}
+
+func (r *rumourMongering) Receive(s *Simulation, from, to int) {
+	informed := s.Inform(to, from, Pushed)
+	if informed {
+		// anything new rekindles its interest
+		r.contacts[to] = 0
+	}
+	if s.State(from) != Infected || (informed && r.response == feedback) {
+		return
+	}
+
+	switch r.loss {
+	case counter:
+		r.contacts[from]++
+		if r.contacts[from] >= s.interest {
+			s.Remove(from)
+		}
+	case coin:
+		if s.rand.Intn(s.interest) == 0 {
+			s.Remove(from)
+		}
+	}
+}
+
+// Done reports true once no informed node is still hot.
+func (r *rumourMongering) Done(s *Simulation) bool {
+	return s.Count(Infected) == 0 || s.spread <= 0
+}
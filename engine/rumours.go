@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"math"
+	"math/bits"
+)
+
+// MaxRumours is how many rumours can spread at once, each from its own
+// starting node.
+const MaxRumours = 64
+
+// Rumours returns the set of rumours id holds, with bit r set if it holds
+// the rumour started r-th.
+func (s *Simulation) Rumours(id int) uint64 {
+	return s.held[id]
+}
+
+// Complete reports whether id holds every rumour that was started.
+func (s *Simulation) Complete(id int) bool {
+	return s.held[id] == s.all
+}
+
+// Uninformed returns how many nodes that are still up are missing at least
+// one of the rumours. With a single rumour that is every susceptible node.
+func (s *Simulation) Uninformed() int {
+	return s.missing
+}
+
+// Coverage returns how many nodes that are still up hold each rumour, in
+// the order the rumours were started.
+func (s *Simulation) Coverage() []int {
+	return append([]int(nil), s.coverage...)
+}
+
+// passable returns the rumours id held before the current round started,
+// and so can pass on this round.
+func (s *Simulation) passable(id int) uint64 {
+	if s.heardAt[id] == s.round {
+		return s.held[id] &^ s.fresh[id]
+	}
+	return s.held[id]
+}
+
+// offers reports whether from can pass on a rumour to that it does not hold.
+func (s *Simulation) offers(from, to int) bool {
+	return s.passable(from)&^s.held[to] != 0
+}
+
+// learn adds news to the rumours id holds, during the current round.
+func (s *Simulation) learn(id int, news uint64) {
+	if s.heardAt[id] != s.round {
+		s.fresh[id] = 0
+	}
+	s.fresh[id] |= news
+	s.heardAt[id] = s.round
+
+	complete := s.Complete(id)
+	s.held[id] |= news
+	if !complete && s.Complete(id) {
+		s.missing--
+	}
+	for news != 0 {
+		s.coverage[bits.TrailingZeros64(news)]++
+		news &= news - 1
+	}
+}
+
+// forget stops counting the rumours a node that crashed or left holds
+// towards coverage.
+func (s *Simulation) forget(id int) {
+	if !s.Complete(id) {
+		s.missing--
+	}
+	for held := s.held[id]; held != 0; held &= held - 1 {
+		s.coverage[bits.TrailingZeros64(held)]--
+	}
+}
+
+// tally counts the nodes holding each rumour, once every starting node has
+// been picked.
+func (s *Simulation) tally() {
+	s.missing = 0
+	clear(s.coverage)
+	for id, held := range s.held {
+		if s.down(id) {
+			continue
+		}
+		if held != s.all {
+			s.missing++
+		}
+		for ; held != 0; held &= held - 1 {
+			s.coverage[bits.TrailingZeros64(held)]++
+		}
+	}
+	s.best = math.MinInt
+	s.improvedIn = s.round
+}
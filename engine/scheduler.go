@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"time"
+)
+
+// roundDuration is how much simulated time a round takes when latency is
+// on. Every node makes its sends at the start of a round.
+const roundDuration = 100 * time.Millisecond
+
+// WithLatency delays every send by perCell for each cell of Euclidean
+// distance between sender and receiver, plus a random jitter of up to
+// jitter. Sends then take effect in the order they arrive rather than the
+// order they were made, and may arrive rounds later. With both at 0, sends
+// take effect immediately, in lock-step rounds.
+func WithLatency(perCell, jitter time.Duration) Option {
+	return func(s *Simulation) {
+		s.latency = perCell
+		s.jitter = jitter
+	}
+}
+
+// delivery is a send in flight.
+type delivery struct {
+	at        time.Duration // simulated time it arrives
+	seq       int           // breaks ties in the order sends were made
+	from, to  int
+	reconcile bool // an anti-entropy exchange rather than a protocol contact
+}
+
+// deliveries is a min-heap of sends in flight, ordered by arrival.
+type deliveries []delivery
+
+func (d deliveries) Len() int { return len(d) }
+func (d deliveries) Less(i, j int) bool {
+	if d[i].at != d[j].at {
+		return d[i].at < d[j].at
+	}
+	return d[i].seq < d[j].seq
+}
+func (d deliveries) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d *deliveries) Push(x any)   { *d = append(*d, x.(delivery)) }
+func (d *deliveries) Pop() any {
+	old := *d
+	last := old[len(old)-1]
+	*d = old[:len(old)-1]
+	return last
+}
+
+// timed reports whether sends are delayed by latency.
+func (s *Simulation) timed() bool {
+	return s.latency > 0 || s.jitter > 0
+}
+
+// Now returns the current simulated time.
+func (s *Simulation) Now() time.Duration {
+	return s.now
+}
+
+// schedule puts a send from from to to in flight.
+func (s *Simulation) schedule(from, to int, reconcile bool) {
+	a, b := s.nodes[from], s.nodes[to]
+	distance := math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+
+	delay := time.Duration(distance * float64(s.latency))
+	if s.jitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(s.jitter) + 1))
+	}
+
+	s.seq++
+	heap.Push(&s.inFlight, delivery{at: s.now + delay, seq: s.seq, from: from, to: to, reconcile: reconcile})
+}
+
+// awaited reports whether a send in flight could still pass on a rumour,
+// between nodes that are still up. Sends between nodes that hold the same
+// rumours, such as those a partition keeps from the rest, change nothing.
+func (s *Simulation) awaited() bool {
+	for _, d := range s.inFlight {
+		if !s.down(d.from) && !s.down(d.to) && (s.offers(d.from, d.to) || s.offers(d.to, d.from)) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver hands over every send arriving before the end of the current
+// round, in the order they arrive, reporting each change to p as it
+// happens. When the simulation is paced, arrivals are spread across the
+// round in proportion to their simulated time.
+func (s *Simulation) deliver(ctx context.Context, p Sender, last time.Time) error {
+	start := s.now
+	end := time.Duration(s.round) * roundDuration
+
+	for len(s.inFlight) > 0 && s.inFlight[0].at < end {
+		d := heap.Pop(&s.inFlight).(delivery)
+		s.now = d.at
+
+		if err := s.pace(ctx, last, float64(d.at-start)/float64(roundDuration)); err != nil {
+			return err
+		}
+
+		if d.reconcile {
+			s.exchange(d.from, d.to)
+		} else {
+			s.protocol.Receive(s, d.from, d.to)
+		}
+
+		if len(s.changes) > 0 {
+			s.lastChange = s.now
+		}
+		s.flush(p)
+	}
+
+	s.now = end
+	return nil
+}
@@ -0,0 +1,202 @@
+package engine
+
+// ReconcileMsg is sent at the end of every round while a state
+// reconciliation protocol runs, and carries how far behind the nodes are and
+// how much state it took to bring them up to date.
+type ReconcileMsg struct {
+	RunID        int
+	Round        int
+	Stale        map[[2]int]float64 // live nodes whose staleness changed, by the fraction of origins they are behind on
+	Behind       int                // live nodes behind on at least one origin
+	Entries      int                // entries transferred in deltas
+	Bytes        int                // bytes transferred, digests included
+	NaiveEntries int                // entries a full state exchange would have transferred
+	NaiveBytes   int
+}
+
+// reconciler is implemented by protocols that reconcile the state of the
+// nodes, rather than spread a rumour.
+type reconciler interface {
+	Protocol
+	reconciled(s *Simulation) ReconcileMsg
+}
+
+// sizes of what a scuttlebutt exchange transfers, in bytes.
+const (
+	digestBytes = 12 // an origin and the highest version seen from it
+	entryBytes  = 40 // an origin, key, value and version
+)
+
+// writes the nodes make to their own state.
+const (
+	keysPerOrigin = 4    // keys each node owns
+	writeChance   = 0.05 // probability that a live node writes a key each round
+	writeRounds   = 30   // rounds the nodes keep writing for
+)
+
+// versions holds the version of each key an origin owns.
+type versions [keysPerOrigin]int
+
+func (v versions) max() int {
+	var highest int
+	for _, version := range v {
+		highest = max(highest, version)
+	}
+	return highest
+}
+
+// scuttlebutt reconciles state with the Scuttlebutt anti-entropy protocol,
+// after van Renesse et al., "Efficient Reconciliation and Flow Control for
+// Anti-Entropy Protocols". Every node owns a few keys, and writes a new
+// version of one of them now and then for the first writeRounds rounds.
+// Every round each live node contacts spread random peers, and the two swap
+// digests holding the highest version they have seen from each origin, then
+// send each other only the entries newer than the other's digest.
+//
+// No rumour is spread: the simulation needs no starting node, and runs
+// until the writes have stopped and every live node holds the newest
+// version any live node has of every key.
+type scuttlebutt struct {
+	state        []map[int]versions // what each node knows, by origin
+	size         []int              // entries each node holds
+	stale        []float64          // staleness last reported for each node
+	behind       int                // live nodes behind, as last reported
+	entries      int
+	bytes        int
+	naiveEntries int
+	naiveBytes   int
+}
+
+func newScuttlebutt() Protocol {
+	return &scuttlebutt{}
+}
+
+func (b *scuttlebutt) rumourless() {}
+
+func (b *scuttlebutt) Targets(s *Simulation, id int) []int {
+	if b.state == nil {
+		for range s.nodes {
+			b.add()
+		}
+	}
+	if s.Round() <= writeRounds && s.rand.Float64() < writeChance {
+		b.write(s, id)
+	}
+	return s.randomPeers(id, s.spread)
+}
+
+// Receive runs an exchange started by from: to answers from's digest with
+// the entries from is missing and its own digest, and from answers with the
+// entries to is missing.
+func (b *scuttlebutt) Receive(s *Simulation, from, to int) {
+	if s.down(to) {
+		return
+	}
+	b.bytes += digestBytes * len(b.state[from])
+	b.naiveEntries += b.size[from] + b.size[to]
+	b.naiveBytes += entryBytes * (b.size[from] + b.size[to])
+
+	b.bytes += digestBytes * len(b.state[to])
+	if !s.send(to, from) {
+		return
+	}
+	b.transfer(to, from)
+
+	if !s.send(from, to) {
+		return
+	}
+	b.transfer(from, to)
+}
+
+func (b *scuttlebutt) Done(s *Simulation) bool {
+	return s.spread <= 0 || b.behind == 0 && s.Round() > writeRounds
+}
+
+func (b *scuttlebutt) lagging(s *Simulation) int {
+	return b.behind
+}
+
+func (b *scuttlebutt) Join(s *Simulation, id int) {
+	if b.state != nil {
+		b.add()
+	}
+}
+
+// add gives a new node its own keys, written before it joined.
+func (b *scuttlebutt) add() {
+	id := len(b.state)
+	b.state = append(b.state, map[int]versions{id: {1, 2, 3, 4}})
+	b.size = append(b.size, keysPerOrigin)
+	b.stale = append(b.stale, -1)
+}
+
+// write has id write a new version of one of its keys.
+func (b *scuttlebutt) write(s *Simulation, id int) {
+	own := b.state[id][id]
+	own[s.rand.Intn(keysPerOrigin)] = own.max() + 1
+	b.state[id][id] = own
+}
+
+// transfer sends from's entries that are newer than to's digest to to.
+func (b *scuttlebutt) transfer(from, to int) {
+	for origin, theirs := range b.state[from] {
+		mine := b.state[to][origin]
+		seen := mine.max()
+		for key, version := range theirs {
+			if version > seen {
+				if mine[key] == 0 {
+					b.size[to]++
+				}
+				mine[key] = version
+				b.entries++
+				b.bytes += entryBytes
+			}
+		}
+		b.state[to][origin] = mine
+	}
+}
+
+// reconciled works out how far behind each live node is on the newest
+// version any live node has seen from each origin that has not left.
+func (b *scuttlebutt) reconciled(s *Simulation) ReconcileMsg {
+	newest := make(map[int]int)
+	for id, state := range b.state {
+		if s.down(id) {
+			continue
+		}
+		for origin, v := range state {
+			if s.states[origin] != Departed {
+				newest[origin] = max(newest[origin], v.max())
+			}
+		}
+	}
+
+	msg := ReconcileMsg{
+		Stale:        make(map[[2]int]float64),
+		Entries:      b.entries,
+		Bytes:        b.bytes,
+		NaiveEntries: b.naiveEntries,
+		NaiveBytes:   b.naiveBytes,
+	}
+	for id, state := range b.state {
+		if s.down(id) {
+			continue
+		}
+		var behind int
+		for origin, version := range newest {
+			if state[origin].max() < version {
+				behind++
+			}
+		}
+		if behind > 0 {
+			msg.Behind++
+		}
+		stale := float64(behind) / float64(max(len(newest)-1, 1))
+		if stale != b.stale[id] {
+			b.stale[id] = stale
+			msg.Stale[s.coord(id)] = stale
+		}
+	}
+	b.behind = msg.Behind
+	return msg
+}
@@ -0,0 +1,601 @@
+// Package engine runs gossip simulations over a set of nodes laid out on a
+// two dimensional grid. It is used by both the local app and the ssh server,
+// and can be driven by any caller that can receive progress messages.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// Node is a single participant, positioned at X, Y on the grid.
+type Node struct {
+	X, Y int
+}
+
+// Simulation holds the node layout and the state of every node as one or
+// more rumours spread.
+type Simulation struct {
+	nodes                            []Node //index is the id
+	states                           []State
+	counts                           [numStates]int // nodes in each state
+	informedAt                       []int          // round each node was first informed in, -1 if it is not
+	via                              []Via          // how each node was last informed
+	held, fresh                      []uint64       // rumours each node holds, and those it learned in heardAt
+	heardAt                          []int          // round each node last learned a rumour in
+	all                              uint64         // every rumour started
+	coverage                         []int          // live nodes holding each rumour
+	missing                          int            // live nodes missing a rumour
+	nodeMap                          map[[2]int]int // x,y mapped to node id
+	protocolName                     string
+	topology                         string
+	topologyParam                    float64
+	graph                            [][]int // nodes linked to each node, nil under the complete topology
+	ends                             []int   // ends of every scale-free link, for preferential attachment
+	regraphed                        bool    // nodes joined or left the topology since the last TopologyMsg
+	protocol                         Protocol
+	changes                          []Change // nodes that changed state since the last RelayMsg
+	height, width, spread, nodeCount int
+	interest                         int
+	antiEntropy                      int // rounds between reconciliations, 0 if off
+	digest                           bool
+	seed                             int64
+	seeded                           bool
+	rand                             *rand.Rand // every random choice is made with this
+	round                            int
+	best                             int // most progress made so far, see progress
+	improvedIn                       int // round best was reached in
+	idle                             int // rounds in a row nothing was lagging, under a protocol that spreads no rumour
+	messages, redundant              int
+	crashes, crashRound              int
+	loss                             float64 // probability that a send is dropped
+	lost                             int
+	dropped                          [][2]int // targets of sends lost since the last RelayMsg
+	partitions                       partitions
+	kills                            kills
+	updates                          pendingUpdates
+	partitioned                      int     // sends dropped by a partition
+	byzantine                        float64 // fraction of nodes that misbehave
+	byzantineBehaviours              []Behaviour
+	behaviours                       []Behaviour
+	forged                           []bool // nodes holding the forged value
+	present                          []int  // nodes that have not left, in no particular order
+	position                         []int  // index of each node in present, -1 once it has left
+	joinRate, leaveRate              float64
+	joined, left                     int
+	latency, jitter                  time.Duration
+	inFlight                         deliveries
+	seq                              int
+	now, lastChange                  time.Duration // simulated time
+	control                          control
+	runID                            int
+}
+
+// Option configures a Simulation created with New.
+type Option func(*Simulation)
+
+// WithSize sets the width and height of the grid the nodes are placed on.
+func WithSize(width, height int) Option {
+	return func(s *Simulation) {
+		s.width = width
+		s.height = height
+	}
+}
+
+// WithNodes sets the number of nodes to place on the grid.
+func WithNodes(count int) Option {
+	return func(s *Simulation) {
+		s.nodeCount = count
+	}
+}
+
+// WithSpread sets how many nodes each informed node relays the rumour to.
+func WithSpread(spread int) Option {
+	return func(s *Simulation) {
+		s.spread = spread
+	}
+}
+
+// WithInterest sets k, how long rumour mongering nodes stay interested in
+// the rumour. It defaults to 1.
+func WithInterest(k int) Option {
+	return func(s *Simulation) {
+		s.interest = k
+	}
+}
+
+// WithAntiEntropy makes every node reconcile with a random peer once every
+// period rounds, alongside the protocol. With digest set, peers compare a
+// digest first and only transfer state when it differs; otherwise they
+// exchange their full state every time. A period of 0 turns it off.
+func WithAntiEntropy(period int, digest bool) Option {
+	return func(s *Simulation) {
+		s.antiEntropy = period
+		s.digest = digest
+	}
+}
+
+// WithSeed seeds every random choice the simulation makes, from the layout
+// to the protocol's decisions. Simulations with the same seed and options
+// produce identical runs. Without it a seed is picked at random.
+func WithSeed(seed int64) Option {
+	return func(s *Simulation) {
+		s.seed = seed
+		s.seeded = true
+	}
+}
+
+// WithProtocol picks the registered protocol the rumour spreads with. The
+// first protocol returned by Protocols is used by default.
+func WithProtocol(name string) Option {
+	return func(s *Simulation) {
+		s.protocolName = name
+	}
+}
+
+// Sender receives the progress messages of a running simulation.
+type Sender interface {
+	Send(msg any)
+}
+
+// Via describes how a node was informed.
+type Via int
+
+const (
+	Started  Via = iota // picked as a starting node
+	Pushed              // sent the rumour by a peer
+	Pulled              // asked a peer that had the rumour
+	Repaired            // reconciled with a peer by anti-entropy
+)
+
+// Change is a node that moved to a new state while the simulation ran.
+type Change struct {
+	Coord   [2]int
+	State   State
+	Via     Via    // how the node was informed
+	Forged  bool   // the node holds the forged value
+	Rumours uint64 // the rumours the node holds, bit r for the rumour started r-th
+	Health  Health // how the other nodes see it, under a membership protocol
+	From    [2]int // node that informed it, if it was informed by a peer
+	Relayed bool   // the node was informed by From
+}
+
+// RelayMsg is sent while a simulation is running and carries the nodes that
+// changed state since the previous RelayMsg. One is always sent at the end
+// of every round.
+type RelayMsg struct {
+	RunID    int
+	Round    int
+	Changes  []Change
+	Lost     [][2]int // nodes that a lost send was meant for
+	Coverage []int    // live nodes holding each rumour, only sent at the end of a round
+}
+
+// SimulationStatusMsg is sent once the simulation has finished.
+type SimulationStatusMsg struct {
+	RunID       int
+	Done        bool
+	Iteration   int
+	Time        time.Duration
+	SimTime     time.Duration // simulated time of the last change, when latency is on
+	Messages    int           // contacts made between nodes, including lost ones
+	Lost        int           // contacts dropped by the network
+	Partitioned int           // contacts dropped by a partition
+	Redundant   int           // contacts that carried the rumour to a node that already had it
+	Nodes       int           // nodes in the network when it finished, including crashed ones
+	Joined      int           // nodes that joined while it ran
+	Left        int           // nodes that left while it ran
+	Crashed     int
+	Informed    int   // live nodes that received the rumour, the rest are the residue
+	Byzantine   int   // nodes that misbehave
+	Misled      int   // live honest nodes that hold the forged value
+	Coverage    []int // live nodes holding each rumour, in the order they were started
+	Seed        int64
+}
+
+// New creates a simulation and places its nodes at random, unique positions.
+func New(opts ...Option) (*Simulation, error) {
+	s := &Simulation{}
+	s.control.wake = make(chan struct{}, 1)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if !s.seeded {
+		s.seed = time.Now().UnixNano()
+	}
+	s.rand = rand.New(rand.NewSource(s.seed))
+
+	if s.interest <= 0 {
+		s.interest = 1
+	}
+
+	if s.nodeCount < 0 || s.nodeCount > s.width*s.height {
+		return nil, fmt.Errorf("engine: %d nodes do not fit in a %dx%d grid", s.nodeCount, s.width, s.height)
+	}
+
+	protocol, err := newProtocol(s.protocolName)
+	if err != nil {
+		return nil, err
+	}
+	s.protocol = protocol
+
+	if _, ok := protocol.(antiEntropyOnly); ok && s.antiEntropy <= 0 {
+		s.antiEntropy = 1
+	}
+
+	s.loadNodes()
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	if o, ok := protocol.(overlay); ok {
+		o.build(s)
+	}
+	s.corrupt()
+
+	if s.crashRound <= 0 {
+		s.crash()
+	}
+
+	return s, nil
+}
+
+func (s *Simulation) loadNodes() {
+	s.nodes = make([]Node, s.nodeCount)
+	s.states = make([]State, s.nodeCount)
+	s.counts[Susceptible] = s.nodeCount
+	s.informedAt = make([]int, s.nodeCount)
+	s.via = make([]Via, s.nodeCount)
+	s.held = make([]uint64, s.nodeCount)
+	s.fresh = make([]uint64, s.nodeCount)
+	s.heardAt = make([]int, s.nodeCount)
+	s.present = make([]int, s.nodeCount)
+	s.position = make([]int, s.nodeCount)
+	s.behaviours = make([]Behaviour, s.nodeCount)
+	s.forged = make([]bool, s.nodeCount)
+	s.nodeMap = make(map[[2]int]int)
+
+	for i := range s.nodes {
+		x := s.rand.Intn(s.width)
+		y := s.rand.Intn(s.height)
+
+		pixel := [2]int{x, y}
+		for _, taken := s.nodeMap[pixel]; taken; _, taken = s.nodeMap[pixel] {
+			x = s.rand.Intn(s.width)
+			y = s.rand.Intn(s.height)
+			pixel = [2]int{x, y}
+
+		}
+		s.nodes[i] = Node{X: x, Y: y}
+		s.nodeMap[pixel] = i
+		s.informedAt[i] = -1
+		s.heardAt[i] = -1
+		s.present[i] = i
+		s.position[i] = i
+
+	}
+}
+
+// Nodes returns the node layout. The index of each node is its id.
+func (s *Simulation) Nodes() []Node {
+	return s.nodes
+}
+
+// NodeAt returns the id of the node at x, y, if there is one.
+func (s *Simulation) NodeAt(x, y int) (int, bool) {
+	id, ok := s.nodeMap[[2]int{x, y}]
+	return id, ok
+}
+
+// Start starts a new rumour at id, which spreads once Run is called. Each
+// call starts another rumour, up to MaxRumours of them. It reports false if
+// id is not a node or is already informed, or if no more rumours can be
+// started.
+func (s *Simulation) Start(id int) bool {
+	if id < 0 || id >= len(s.nodes) || s.states[id] != Susceptible || len(s.coverage) >= MaxRumours {
+		return false
+	}
+	rumour := uint64(1) << len(s.coverage)
+	s.coverage = append(s.coverage, 1)
+	s.all |= rumour
+
+	s.setState(id, Infected)
+	s.informedAt[id] = 0
+	s.via[id] = Started
+	s.heardAt[id] = 0
+	s.held[id] = rumour
+	s.forged[id] = s.behaviours[id] == Forging
+	return true
+}
+
+// Started reports whether a starting node has been chosen.
+func (s *Simulation) Started() bool {
+	return s.informed() > 0
+}
+
+// NeedsStart reports whether a starting node has to be chosen before Run.
+// Protocols that spread no rumour, such as membership protocols, do not
+// need one.
+func (s *Simulation) NeedsStart() bool {
+	_, ok := s.protocol.(rumourless)
+	return !ok
+}
+
+// Seed returns the seed the simulation's randomness is drawn from.
+func (s *Simulation) Seed() int64 {
+	return s.seed
+}
+
+// Rand returns the source of every random choice in the simulation.
+// Protocols should use it, rather than the global source, so that runs can
+// be reproduced from their seed.
+func (s *Simulation) Rand() *rand.Rand {
+	return s.rand
+}
+
+// Round returns the current round. Starting nodes are informed in round 0.
+func (s *Simulation) Round() int {
+	return s.round
+}
+
+// Spread returns how many peers a node contacts per round.
+func (s *Simulation) Spread() int {
+	return s.spread
+}
+
+// randomPeers picks up to count distinct nodes other than id that have not
+// left, uniformly at random, from those the topology links it to.
+func (s *Simulation) randomPeers(id, count int) []int {
+	if s.graph != nil {
+		peers := s.neighbours(id)
+		s.rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+		return peers[:min(count, len(peers))]
+	}
+	if count >= len(s.present)-1 {
+		peers := make([]int, 0, len(s.present))
+		for _, peer := range s.present {
+			if peer != id {
+				peers = append(peers, peer)
+			}
+		}
+		return peers
+	}
+
+	peers := make([]int, 0, count)
+	for len(peers) < count {
+		peer := s.present[s.rand.Intn(len(s.present))]
+		if peer != id && !slices.Contains(peers, peer) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// ErrNotStarted is returned by Run if no starting node was chosen, and the
+// protocol needs one.
+var ErrNotStarted = errors.New("engine: no starting node")
+
+// Run spreads the rumour from the starting nodes until the protocol is done,
+// reporting progress to p. Every message it sends is tagged with id, so the
+// receiver can tell runs apart. It blocks until the simulation is finished,
+// or returns ctx's error as soon as ctx is cancelled.
+func (s *Simulation) Run(ctx context.Context, id int, p Sender) error {
+
+	if s.NeedsStart() && !s.Started() {
+		return ErrNotStarted
+	}
+
+	s.runID = id
+	s.tally()
+	start := time.Now()
+	last := start
+
+	for !s.done() {
+		if err := s.wait(ctx, last); err != nil {
+			return err
+		}
+		last = time.Now()
+
+		s.round++
+		s.now = time.Duration(s.round-1) * roundDuration
+		if s.crashRound == s.round {
+			s.crash()
+		}
+		s.kill()
+		s.update()
+		if s.round == 1 {
+			s.forge()
+		}
+		s.partition(p)
+		s.churn()
+
+		for id := range s.nodes {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if s.down(id) || s.dropping(id) {
+				continue
+			}
+
+			targets := s.protocol.Targets(s, id)
+			if len(targets) == 0 {
+				continue
+			}
+
+			for _, target := range targets {
+				if !s.send(id, target) {
+					continue
+				}
+				if s.timed() {
+					s.schedule(id, target, false)
+					continue
+				}
+				s.protocol.Receive(s, id, target)
+			}
+
+			s.flush(p)
+		}
+
+		s.reconcile()
+		if err := s.deliver(ctx, p, last); err != nil {
+			return err
+		}
+		p.Send(RelayMsg{RunID: s.runID, Round: s.round, Changes: s.changes, Lost: s.dropped, Coverage: s.Coverage()})
+		s.changes = nil
+		s.dropped = nil
+		if m, ok := s.protocol.(membership); ok {
+			msg := m.status(s)
+			msg.RunID, msg.Round = s.runID, s.round
+			p.Send(msg)
+		}
+		if b, ok := s.protocol.(broadcaster); ok {
+			msg := b.counts()
+			msg.RunID, msg.Round = s.runID, s.round
+			p.Send(msg)
+		}
+		if r, ok := s.protocol.(reconciler); ok {
+			msg := r.reconciled(s)
+			msg.RunID, msg.Round = s.runID, s.round
+			p.Send(msg)
+		}
+		if u, ok := s.protocol.(updatable); ok {
+			msg := u.convergence(s)
+			msg.RunID, msg.Round = s.runID, s.round
+			p.Send(msg)
+		}
+		if s.regraphed {
+			s.regraphed = false
+			p.Send(TopologyMsg{RunID: s.runID, Round: s.round, Edges: s.Topology()})
+		}
+		if o, ok := s.protocol.(overlay); ok {
+			if edges, changed := o.edges(s); changed {
+				p.Send(OverlayMsg{RunID: s.runID, Round: s.round, Edges: edges})
+			}
+		}
+
+		s.progress()
+	}
+
+	p.Send(SimulationStatusMsg{
+		RunID:       s.runID,
+		Done:        true,
+		Iteration:   s.round,
+		Time:        time.Since(start),
+		SimTime:     s.lastChange,
+		Messages:    s.messages,
+		Lost:        s.lost,
+		Partitioned: s.partitioned,
+		Redundant:   s.redundant,
+		Nodes:       len(s.present),
+		Joined:      s.joined,
+		Left:        s.left,
+		Crashed:     s.Count(Crashed),
+		Informed:    s.informed(),
+		Byzantine:   s.corrupted(),
+		Misled:      s.misled(),
+		Coverage:    s.Coverage(),
+		Seed:        s.seed,
+	})
+
+	return nil
+}
+
+// stallRounds is how many rounds may pass without more progress than ever
+// before, before a simulation that is not otherwise done is considered
+// stuck. With anti-entropy on it is at least two periods, so that
+// reconciliation gets a chance to repair the nodes.
+const stallRounds = 100
+
+// stalled reports whether no progress has been made for stallRounds rounds,
+// and no crash or partition is still to come.
+func (s *Simulation) stalled() bool {
+	window := max(stallRounds, 2*s.antiEntropy)
+	return s.round-s.improvedIn >= window && s.crashRound <= s.round && !s.healing()
+}
+
+// idleRounds is how many rounds in a row a protocol that spreads no rumour
+// may have nothing lagging behind, such as while it waits for a failure,
+// before a simulation that is not otherwise done ends. Idle rounds run no
+// faster than one every idleInterval, so that waiting costs little.
+const (
+	idleRounds   = 600
+	idleInterval = 100 * time.Millisecond
+)
+
+// idled reports whether nothing has lagged for idleRounds rounds, and no
+// crash or partition is still to come.
+func (s *Simulation) idled() bool {
+	return s.idle >= idleRounds && s.crashRound <= s.round && !s.healing()
+}
+
+// progress notes the round if more progress has been made than ever before.
+// Progress is how many rumours the live nodes hold between them, so that
+// rumours spreading apart count even before any node holds them all. Under
+// a protocol that spreads no rumour it is how little it reports as lagging,
+// and once nothing lags it starts over, so that anything that falls behind
+// again gets a whole window to catch up.
+func (s *Simulation) progress() {
+	var made int
+	for _, count := range s.coverage {
+		made += count
+	}
+	if l, ok := s.protocol.(lagging); ok {
+		behind := l.lagging(s)
+		if behind == 0 {
+			s.idle++
+			s.best = math.MinInt
+			s.improvedIn = s.round
+			return
+		}
+		s.idle = 0
+		made = -behind
+	}
+	if made > s.best {
+		s.best = made
+		s.improvedIn = s.round
+	}
+}
+
+// done reports whether the protocol is finished and, when anti-entropy is
+// on, every live node has been repaired with every rumour. It is also done once every
+// informed node has crashed, since the rumour can go no further, or once it
+// has made no progress for stallRounds rounds and no crash or partition is
+// still to come, such as when a partition that never heals cuts off the
+// rest of the nodes, or churn brings in new nodes as fast as they are
+// informed. It is never done while sends are in flight to nodes
+// that could still use them. A protocol that spreads no rumour is done when
+// it says so or, if it reports what is lagging, once it stops catching up or
+// has had nothing to catch up on for idleRounds, unless it takes local
+// updates, which can set it going again at any time.
+func (s *Simulation) done() bool {
+	if _, ok := s.protocol.(rumourless); ok {
+		_, lags := s.protocol.(lagging)
+		_, updates := s.protocol.(updatable)
+		return s.protocol.Done(s) || lags && !updates && (s.stalled() || s.idled())
+	}
+	if s.awaited() {
+		return false
+	}
+	if s.informed() == 0 || s.stalled() {
+		return true
+	}
+	if !s.protocol.Done(s) {
+		return false
+	}
+	return s.antiEntropy <= 0 || s.Uninformed() == 0
+}
+
+// flush sends the nodes that changed and the sends lost since the last
+// RelayMsg, if there are any.
+func (s *Simulation) flush(p Sender) {
+	if len(s.changes) > 0 || len(s.dropped) > 0 {
+		p.Send(RelayMsg{RunID: s.runID, Round: s.round, Changes: s.changes, Lost: s.dropped})
+		s.changes = nil
+		s.dropped = nil
+	}
+}
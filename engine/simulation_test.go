@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recorder keeps every message a simulation sends, and cancels the run once
+// it reaches round limit.
+type recorder struct {
+	msgs   []any
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (r *recorder) Send(msg any) {
+	switch m := msg.(type) {
+	case SimulationStatusMsg:
+		m.Time = 0 // the only field that depends on the wall clock
+		msg = m
+	case RelayMsg:
+		if m.Round >= r.limit {
+			r.cancel()
+		}
+	}
+	r.msgs = append(r.msgs, msg)
+}
+
+// record runs a simulation built from opts for up to limit rounds, as fast
+// as it can, and returns every message it sent.
+func record(t *testing.T, protocol string, limit int, opts ...Option) []any {
+	t.Helper()
+	s, err := New(append(opts, WithProtocol(protocol))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NeedsStart() {
+		s.Start(0)
+	}
+	if s.Updatable() {
+		n := s.Nodes()[1]
+		s.Update(n.X, n.Y, false)
+	}
+	// stepping runs rounds straight away, even while the run is idle
+	for range limit {
+		s.Step()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r := &recorder{limit: limit, cancel: cancel}
+	if err := s.Run(ctx, 1, r); err != nil && err != context.Canceled {
+		t.Fatalf("%s: %v", protocol, err)
+	}
+	return r.msgs
+}
+
+// finish runs a simulation built from opts, after setup, until it is done,
+// and returns its final status. It fails if the run does not end in time.
+func finish(t *testing.T, setup func(s *Simulation), opts ...Option) SimulationStatusMsg {
+	t.Helper()
+	s, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NeedsStart() {
+		s.Start(0)
+	}
+	if setup != nil {
+		setup(s)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r := &recorder{limit: math.MaxInt, cancel: cancel}
+	if err := s.Run(ctx, 1, r); err != nil {
+		t.Fatalf("run did not finish: %v", err)
+	}
+	return r.msgs[len(r.msgs)-1].(SimulationStatusMsg)
+}
+
+func TestSeedReproducesRuns(t *testing.T) {
+	configs := map[string][]Option{
+		"plain": {WithSize(60, 30), WithNodes(200), WithSpread(2), WithSeed(7)},
+		"faults": {
+			WithSize(60, 30), WithNodes(200), WithSpread(2), WithSeed(7),
+			WithLoss(0.1), WithCrashes(3, 5), WithChurn(0.5, 0.5), WithByzantine(0.05),
+			WithLatency(5*time.Millisecond, 20*time.Millisecond), WithAntiEntropy(4, true),
+			WithTopology("small world", 6),
+		},
+	}
+	for name, opts := range configs {
+		for _, protocol := range Protocols() {
+			first := record(t, protocol, 200, opts...)
+			second := record(t, protocol, 200, opts...)
+			if len(first) == 0 {
+				t.Errorf("%s, %s: no messages sent", name, protocol)
+			}
+			if !reflect.DeepEqual(first, second) {
+				t.Errorf("%s, %s: two runs with the same seed sent different messages", name, protocol)
+			}
+		}
+	}
+}
+
+// Rumours started far apart on a sparse topology spread for a long time
+// before any node holds them all, which must not be taken for a stall.
+func TestStallCountsEveryRumour(t *testing.T) {
+	apart := func(s *Simulation) {
+		far := 0
+		for id := range s.nodes {
+			if s.distance(0, id) > s.distance(0, far) {
+				far = id
+			}
+		}
+		s.Start(far)
+	}
+	for _, topology := range []string{"ring", "grid"} {
+		for _, protocol := range []string{"nearest push", "random push"} {
+			status := finish(t, apart, WithSize(200, 50), WithNodes(800), WithSpread(2), WithSeed(7),
+				WithProtocol(protocol), WithTopology(topology, 0))
+			for rumour, coverage := range status.Coverage {
+				if coverage != status.Nodes {
+					t.Errorf("%s, %s: rumour %d reached %d of %d nodes, want all", topology, protocol, rumour, coverage, status.Nodes)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"cmp"
+	"slices"
+)
+
+// kdTree is a 2-d tree over node positions, so that the nodes nearest a
+// point are found without looking at every node. Nodes are removed by
+// marking them, and every subtree counts the nodes still in it and keeps
+// the box around them, so that searches skip the parts of the tree that
+// were emptied or are too far away.
+type kdTree struct {
+	cells []kdCell
+	root  int   // -1 while the tree is empty
+	at    []int // cell of each node, -1 if it was never in the tree
+}
+
+type kdCell struct {
+	id                  int
+	x                   bool // split on X, or else on Y
+	left, right, parent int  // -1 if there is none
+	in                  bool // the node is still in the tree
+	live                int  // nodes still in the subtree, this one included
+	min, max            Node // corners of the box around every node ever in the subtree
+}
+
+// newKDTree builds a balanced tree holding ids.
+func newKDTree(nodes []Node, ids []int) *kdTree {
+	t := &kdTree{}
+	t.root = t.build(nodes, slices.Clone(ids), true, -1)
+	return t
+}
+
+// build builds the subtree holding ids, splitting on X first if x is set,
+// and returns its cell.
+func (t *kdTree) build(nodes []Node, ids []int, x bool, parent int) int {
+	if len(ids) == 0 {
+		return -1
+	}
+	slices.SortFunc(ids, func(a, b int) int {
+		return cmp.Or(cmp.Compare(axis(nodes[a], x), axis(nodes[b], x)), cmp.Compare(a, b))
+	})
+	mid := len(ids) / 2
+	cell := t.place(nodes, ids[mid], x, parent)
+	left := t.build(nodes, ids[:mid], !x, cell)
+	right := t.build(nodes, ids[mid+1:], !x, cell)
+	t.cells[cell].left, t.cells[cell].right = left, right
+	t.cells[cell].live = 1 + t.live(left) + t.live(right)
+	for _, child := range []int{left, right} {
+		if child >= 0 {
+			t.grow(cell, t.cells[child].min)
+			t.grow(cell, t.cells[child].max)
+		}
+	}
+	return cell
+}
+
+// place adds a cell holding id, with no children, and returns it.
+func (t *kdTree) place(nodes []Node, id int, x bool, parent int) int {
+	for len(t.at) <= id {
+		t.at = append(t.at, -1)
+	}
+	t.at[id] = len(t.cells)
+	t.cells = append(t.cells, kdCell{id: id, x: x, left: -1, right: -1, parent: parent, in: true, live: 1, min: nodes[id], max: nodes[id]})
+	return t.at[id]
+}
+
+// grow widens the box of cell to take in n.
+func (t *kdTree) grow(cell int, n Node) {
+	c := &t.cells[cell]
+	c.min = Node{X: min(c.min.X, n.X), Y: min(c.min.Y, n.Y)}
+	c.max = Node{X: max(c.max.X, n.X), Y: max(c.max.Y, n.Y)}
+}
+
+func (t *kdTree) live(cell int) int {
+	if cell < 0 {
+		return 0
+	}
+	return t.cells[cell].live
+}
+
+func axis(n Node, x bool) int {
+	if x {
+		return n.X
+	}
+	return n.Y
+}
+
+// add puts a node that joined into the tree, as a new leaf.
+func (t *kdTree) add(nodes []Node, id int) {
+	if t.root < 0 {
+		t.root = t.place(nodes, id, true, -1)
+		return
+	}
+	for cell := t.root; ; {
+		t.cells[cell].live++
+		t.grow(cell, nodes[id])
+		c := t.cells[cell]
+		left := axis(nodes[id], c.x) < axis(nodes[c.id], c.x)
+		next := c.right
+		if left {
+			next = c.left
+		}
+		if next >= 0 {
+			cell = next
+			continue
+		}
+
+		leaf := t.place(nodes, id, !c.x, cell)
+		if left {
+			t.cells[cell].left = leaf
+		} else {
+			t.cells[cell].right = leaf
+		}
+		return
+	}
+}
+
+// remove takes id out of the tree.
+func (t *kdTree) remove(id int) {
+	if id >= len(t.at) || t.at[id] < 0 || !t.cells[t.at[id]].in {
+		return
+	}
+	cell := t.at[id]
+	t.cells[cell].in = false
+	for ; cell >= 0; cell = t.cells[cell].parent {
+		t.cells[cell].live--
+	}
+}
+
+// nearest returns up to count of the nodes in the tree closest to from that
+// keep accepts, closest first, breaking ties by id.
+func (t *kdTree) nearest(nodes []Node, from Node, count int, keep func(id int) bool) []int {
+	if count <= 0 {
+		return nil
+	}
+	distance := func(id int) int {
+		dx, dy := nodes[id].X-from.X, nodes[id].Y-from.Y
+		return dx*dx + dy*dy
+	}
+	closer := func(a, b int) bool {
+		return distance(a) < distance(b) || distance(a) == distance(b) && a < b
+	}
+	// outside returns how far from lies from the box of a cell, squared
+	outside := func(c kdCell) int {
+		dx := max(c.min.X-from.X, from.X-c.max.X, 0)
+		dy := max(c.min.Y-from.Y, from.Y-c.max.Y, 0)
+		return dx*dx + dy*dy
+	}
+
+	var found []int // the closest so far, in order
+	var search func(cell int)
+	search = func(cell int) {
+		if cell < 0 || t.cells[cell].live == 0 {
+			return
+		}
+		c := t.cells[cell]
+		if len(found) == count && outside(c) > distance(found[count-1]) {
+			return
+		}
+		if c.in && (len(found) < count || closer(c.id, found[count-1])) && keep(c.id) {
+			at := len(found)
+			for at > 0 && closer(c.id, found[at-1]) {
+				at--
+			}
+			found = slices.Insert(found, at, c.id)
+			found = found[:min(len(found), count)]
+		}
+
+		near, far := c.left, c.right
+		if axis(from, c.x) > axis(nodes[c.id], c.x) {
+			near, far = far, near
+		}
+		search(near)
+		search(far)
+	}
+	search(t.root)
+	return found
+}
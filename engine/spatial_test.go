@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// bruteNearest returns up to count of the ids in in closest to from that keep
+// accepts, closest first, breaking ties by id.
+func bruteNearest(nodes []Node, in []bool, from Node, count int, keep func(id int) bool) []int {
+	distance := func(id int) int {
+		dx, dy := nodes[id].X-from.X, nodes[id].Y-from.Y
+		return dx*dx + dy*dy
+	}
+	var ids []int
+	for id := range nodes {
+		if in[id] && keep(id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b int) int {
+		return cmp.Or(cmp.Compare(distance(a), distance(b)), cmp.Compare(a, b))
+	})
+	return ids[:min(count, len(ids))]
+}
+
+func TestKDTreeNearest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	// a small grid, so that many nodes share a cell and distances tie
+	place := func() Node { return Node{X: r.Intn(20), Y: r.Intn(12)} }
+
+	var nodes []Node
+	var ids []int
+	for id := range 300 {
+		nodes = append(nodes, place())
+		ids = append(ids, id)
+	}
+	tree := newKDTree(nodes, ids)
+	in := make([]bool, len(nodes))
+	for id := range in {
+		in[id] = true
+	}
+
+	check := func(step string) {
+		t.Helper()
+		for range 50 {
+			from := place()
+			count := 1 + r.Intn(8)
+			odd := r.Intn(2) == 0
+			keep := func(id int) bool { return !odd || id%2 == 1 }
+
+			got := tree.nearest(nodes, from, count, keep)
+			want := bruteNearest(nodes, in, from, count, keep)
+			if !slices.Equal(got, want) {
+				t.Fatalf("%s: nearest(%v, %d) = %v, want %v", step, from, count, got, want)
+			}
+		}
+	}
+
+	check("built")
+	for id := range nodes {
+		if r.Intn(3) == 0 {
+			tree.remove(id)
+			in[id] = false
+		}
+	}
+	check("after removing")
+	for range 100 {
+		id := len(nodes)
+		nodes = append(nodes, place())
+		in = append(in, true)
+		tree.add(nodes, id)
+	}
+	check("after adding")
+	for id := range nodes {
+		tree.remove(id)
+		in[id] = false
+	}
+	check("empty")
+}
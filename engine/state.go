@@ -0,0 +1,96 @@
+package engine
+
+// State is where a node is in the SIR epidemic model, or whether it has
+// crashed.
+type State int
+
+const (
+	Susceptible State = iota // has not heard the rumour
+	Infected                 // has the rumour and is actively spreading it
+	Removed                  // has the rumour but stopped spreading it
+	Crashed                  // never sends or receives anything again
+	Departed                 // left the network
+	numStates
+)
+
+// State returns the state of id.
+func (s *Simulation) State(id int) State {
+	return s.states[id]
+}
+
+// Count returns how many nodes are in state.
+func (s *Simulation) Count(state State) int {
+	return s.counts[state]
+}
+
+// Informed reports whether id has received a rumour and is still up.
+func (s *Simulation) Informed(id int) bool {
+	return s.states[id] == Infected || s.states[id] == Removed
+}
+
+// informed returns how many nodes that are still up have a rumour.
+func (s *Simulation) informed() int {
+	return s.counts[Infected] + s.counts[Removed]
+}
+
+// InformedAt returns the round id was first informed in, or -1 if it is
+// not.
+func (s *Simulation) InformedAt(id int) int {
+	return s.informedAt[id]
+}
+
+// Inform hands the rumours from held before the current round to id,
+// making id infected with from's value of them, or removed if id drops
+// them. A removed node that learns a rumour it did not hold becomes infected
+// again. Inform reports false if id learned nothing new, if either node has
+// crashed or left, or if from misbehaves and withholds the rumours.
+func (s *Simulation) Inform(id, from int, via Via) bool {
+	if s.down(id) || s.down(from) || !s.relays(from) {
+		return false
+	}
+	news := s.passable(from) &^ s.held[id]
+	if news == 0 {
+		s.redundant++
+		return false
+	}
+	state := Infected
+	if s.behaviours[id] == Dropping {
+		// it will never spread the rumour, so it is removed straight away
+		state = Removed
+	}
+	if !s.Informed(id) {
+		s.informedAt[id] = s.round
+	}
+	s.setState(id, state)
+	s.via[id] = via
+	s.learn(id, news)
+	s.forged[id] = s.forged[id] || s.forged[from] || s.behaviours[id] == Forging
+	s.changes = append(s.changes, Change{Coord: s.coord(id), State: state, Via: via, Forged: s.forged[id], Rumours: s.held[id], From: s.coord(from), Relayed: true})
+	return true
+}
+
+// Remove stops id from spreading the rumours it holds. The change keeps how
+// it was last informed.
+func (s *Simulation) Remove(id int) {
+	if s.states[id] != Infected {
+		return
+	}
+	s.setState(id, Removed)
+	s.changes = append(s.changes, Change{Coord: s.coord(id), State: Removed, Via: s.via[id], Forged: s.forged[id], Rumours: s.held[id]})
+}
+
+// knew reports whether id had a rumour before the current round started,
+// and so can pass it on this round.
+func (s *Simulation) knew(id int) bool {
+	return s.Informed(id) && s.passable(id) != 0
+}
+
+func (s *Simulation) setState(id int, state State) {
+	s.counts[s.states[id]]--
+	s.counts[state]++
+	s.states[id] = state
+}
+
+func (s *Simulation) coord(id int) [2]int {
+	return [2]int{s.nodes[id].X, s.nodes[id].Y}
+}
@@ -0,0 +1,379 @@
+package engine
+
+import (
+	"math"
+	"slices"
+)
+
+// Health is how the other nodes see a node under a membership protocol.
+type Health int
+
+const (
+	Alive     Health = iota // no live node suspects it
+	Suspected               // some live node suspects it has failed
+	Confirmed               // some live node has confirmed it failed
+	Detected                // every live node has confirmed it failed
+)
+
+// MembershipMsg is sent at the end of every round while a membership
+// protocol runs, and carries how well it is detecting failed nodes.
+type MembershipMsg struct {
+	RunID          int
+	Round          int
+	Failed         int     // nodes that crashed
+	Detected       int     // failed nodes that every live node has confirmed
+	FirstDetection float64 // mean rounds from a failure until a live node suspects it
+	FullDetection  float64 // mean rounds from a failure until every live node has confirmed it
+	Suspicions     int     // probes that ended with the target suspected
+	FalsePositives int     // suspicions of targets that were still up
+}
+
+// maxPiggyback is how many membership updates fit on a single message.
+const maxPiggyback = 16
+
+type memberStatus int
+
+const (
+	alive memberStatus = iota
+	suspect
+	dead
+)
+
+// member is what one node believes about another.
+type member struct {
+	status      memberStatus
+	incarnation int
+	since       int // round the node took this status on
+}
+
+// update is a member a node piggybacks on its messages.
+type update struct {
+	node int
+	member
+	sends int // times it is still to be piggybacked
+}
+
+// swim is the SWIM failure detector, following Das et al., "SWIM: Scalable
+// Weakly-consistent Infection-style Process Group Membership Protocol".
+// Every round, which is a protocol period, each live node pings a random
+// member. If no ack comes back it asks k of the others, where k is the
+// interest, to ping the member on its behalf, and suspects it if none of
+// them get an ack either. A suspected member that does not refute the
+// suspicion within a timeout is confirmed dead. Updates spread by being
+// piggybacked on the pings, acks and ping requests, the least sent first.
+// As in memberlist, a live node that hears it was confirmed dead refutes it
+// too, rejoining with a higher incarnation.
+//
+// No rumour is spread: the simulation needs no starting node, and runs
+// until every node that crashed has been detected by all live nodes, or
+// detection stops making progress. Nodes that leave are gone from the
+// membership, so they are not failures.
+type swim struct {
+	views          []map[int]member // each node's view of the others, alive at incarnation 0 if missing
+	incarnation    []int
+	updates        [][]update // updates each node is piggybacking
+	health         []Health
+	failedAt       []int // round each node was found to have crashed in, -1 if it has not
+	suspectedAt    []int // round a failed node was first suspected in, -1 if it has not been
+	detectedAt     []int // round a failed node was detected in, -1 if it has not been
+	failed         []int // failed nodes, in the order they failed
+	round          int   // round last swept
+	suspicions     int
+	falsePositives int
+}
+
+func newSwim() Protocol {
+	return &swim{round: -1}
+}
+
+func (w *swim) Targets(s *Simulation, id int) []int {
+	if w.round != s.Round() {
+		w.sweep(s)
+	}
+
+	target, ok := w.pick(s, id)
+	if !ok {
+		return nil
+	}
+	if w.ping(s, id, target) {
+		return nil
+	}
+	for _, helper := range w.helpers(s, id, target) {
+		if w.deliver(s, id, helper) && w.ping(s, helper, target) && w.deliver(s, helper, id) {
+			return nil
+		}
+	}
+
+	if current := w.views[id][target]; current.status == alive {
+		w.suspicions++
+		if !s.down(target) {
+			w.falsePositives++
+		}
+		w.learn(s, id, target, member{status: suspect, incarnation: current.incarnation})
+	}
+	return nil
+}
+
+func (w *swim) rumourless() {}
+
+// Receive does nothing, since every message of a probe is exchanged within
+// the protocol period that Targets runs.
+func (w *swim) Receive(s *Simulation, from, to int) {}
+
+// Done reports true once every node that crashed has been detected, or no
+// node is left up to detect anything.
+func (w *swim) Done(s *Simulation) bool {
+	if len(w.failed) == 0 {
+		return false
+	}
+	if len(w.failed) == len(s.present) {
+		return true
+	}
+	return w.lagging(s) == 0
+}
+
+// lagging returns how many failed nodes have yet to be detected. While there
+// are none the run idles, and if detecting them stops making progress it is
+// ended, such as when some live nodes never hear of a failure.
+func (w *swim) lagging(s *Simulation) int {
+	var undetected int
+	for _, id := range w.failed {
+		if w.detectedAt[id] < 0 {
+			undetected++
+		}
+	}
+	return undetected
+}
+
+func (w *swim) Join(s *Simulation, id int) {
+	if w.views != nil {
+		w.add()
+	}
+}
+
+func (w *swim) add() {
+	w.views = append(w.views, make(map[int]member))
+	w.incarnation = append(w.incarnation, 0)
+	w.updates = append(w.updates, nil)
+	w.health = append(w.health, Alive)
+	w.failedAt = append(w.failedAt, -1)
+	w.suspectedAt = append(w.suspectedAt, -1)
+	w.detectedAt = append(w.detectedAt, -1)
+}
+
+// sweep runs once at the start of every round: it notes the nodes that
+// crashed, times out suspicions and works out how the group sees each node.
+func (w *swim) sweep(s *Simulation) {
+	w.round = s.Round()
+	for len(w.views) < len(s.nodes) {
+		w.add()
+	}
+
+	for id := range s.nodes {
+		if s.states[id] == Crashed && w.failedAt[id] < 0 {
+			w.failedAt[id] = w.round
+			w.failed = append(w.failed, id)
+		}
+	}
+
+	timeout := w.timeout(s)
+	for id, view := range w.views {
+		if s.down(id) {
+			continue
+		}
+		for _, node := range expired(view, w.round-timeout) {
+			w.learn(s, id, node, member{status: dead, incarnation: view[node].incarnation})
+		}
+	}
+
+	suspects := make([]int, len(s.nodes))
+	deaths := make([]int, len(s.nodes))
+	up := 0
+	for id, view := range w.views {
+		if s.down(id) {
+			continue
+		}
+		up++
+		for node, m := range view {
+			switch m.status {
+			case suspect:
+				suspects[node]++
+			case dead:
+				deaths[node]++
+			}
+		}
+	}
+
+	for id := range s.nodes {
+		observers := up
+		if !s.down(id) {
+			observers--
+		}
+
+		health := Alive
+		switch {
+		case deaths[id] > 0 && deaths[id] >= observers:
+			health = Detected
+		case deaths[id] > 0:
+			health = Confirmed
+		case suspects[id] > 0:
+			health = Suspected
+		}
+		if health == w.health[id] {
+			continue
+		}
+		w.health[id] = health
+
+		if w.failedAt[id] >= 0 && health != Alive && w.suspectedAt[id] < 0 {
+			w.suspectedAt[id] = w.round
+		}
+		if w.failedAt[id] >= 0 && health == Detected && w.detectedAt[id] < 0 {
+			w.detectedAt[id] = w.round
+		}
+		if s.states[id] != Departed {
+			s.changes = append(s.changes, Change{Coord: s.coord(id), State: s.states[id], Health: health})
+		}
+	}
+}
+
+// pick returns a random member that id does not believe is dead.
+func (w *swim) pick(s *Simulation, id int) (int, bool) {
+	for range 3 {
+		peers := s.randomPeers(id, 1)
+		if len(peers) == 0 {
+			return 0, false
+		}
+		if w.views[id][peers[0]].status != dead {
+			return peers[0], true
+		}
+	}
+	return 0, false
+}
+
+// helpers returns up to k members that id can ask to ping target.
+func (w *swim) helpers(s *Simulation, id, target int) []int {
+	peers := slices.DeleteFunc(s.randomPeers(id, s.interest+1), func(peer int) bool {
+		return peer == target || w.views[id][peer].status == dead
+	})
+	return peers[:min(s.interest, len(peers))]
+}
+
+// ping sends a ping from from to to, and reports whether to's ack made it
+// back.
+func (w *swim) ping(s *Simulation, from, to int) bool {
+	return w.deliver(s, from, to) && w.deliver(s, to, from)
+}
+
+// deliver sends a message from from to to with from's updates piggybacked
+// on it, and reports whether to received it.
+func (w *swim) deliver(s *Simulation, from, to int) bool {
+	if !s.send(from, to) || s.down(to) {
+		return false
+	}
+
+	slices.SortStableFunc(w.updates[from], func(a, b update) int { return b.sends - a.sends })
+	var sent int
+	for i := range w.updates[from] {
+		if sent == maxPiggyback {
+			break
+		}
+		u := &w.updates[from][i]
+		w.learn(s, to, u.node, u.member)
+		u.sends--
+		sent++
+	}
+	w.updates[from] = slices.DeleteFunc(w.updates[from], func(u update) bool { return u.sends <= 0 })
+	return true
+}
+
+// learn merges what id has heard about node into its view, and piggybacks
+// it on id's messages if it was news. A node that hears it is suspected or
+// dead refutes it by raising its incarnation.
+func (w *swim) learn(s *Simulation, id, node int, heard member) {
+	if node == id {
+		if heard.status != alive && heard.incarnation >= w.incarnation[id] {
+			w.incarnation[id] = heard.incarnation + 1
+			w.spread(s, id, update{node: id, member: member{status: alive, incarnation: w.incarnation[id]}})
+		}
+		return
+	}
+
+	current := w.views[id][node]
+	if !overrides(heard, current) {
+		return
+	}
+	heard.since = w.round
+	w.views[id][node] = heard
+	w.spread(s, id, update{node: node, member: heard})
+}
+
+// overrides reports whether heard replaces current, by the SWIM rules: a
+// confirmed death overrides anything but a rejoin at a higher incarnation,
+// a suspicion overrides being alive at the same incarnation, and a higher
+// incarnation overrides anything else.
+func overrides(heard, current member) bool {
+	switch {
+	case current.status == dead:
+		return heard.status == alive && heard.incarnation > current.incarnation
+	case heard.status == dead:
+		return true
+	case heard.status == suspect && current.status == alive:
+		return heard.incarnation >= current.incarnation
+	}
+	return heard.incarnation > current.incarnation
+}
+
+// spread queues u to be piggybacked on id's messages, replacing any older
+// update about the same node.
+func (w *swim) spread(s *Simulation, id int, u update) {
+	u.sends = 3 * int(math.Ceil(math.Log10(float64(len(s.present)+1))))
+	w.updates[id] = slices.DeleteFunc(w.updates[id], func(old update) bool { return old.node == u.node })
+	w.updates[id] = append(w.updates[id], u)
+}
+
+// timeout is how many rounds a suspicion lasts before the member is
+// confirmed dead, which grows with the log of the group size.
+func (w *swim) timeout(s *Simulation) int {
+	return max(1, int(math.Ceil(math.Log2(float64(len(s.present)+1)))))
+}
+
+func (w *swim) status(s *Simulation) MembershipMsg {
+	msg := MembershipMsg{
+		Failed:         len(w.failed),
+		Suspicions:     w.suspicions,
+		FalsePositives: w.falsePositives,
+	}
+
+	var suspected, first, full int
+	for _, id := range w.failed {
+		if w.suspectedAt[id] >= 0 {
+			suspected++
+			first += w.suspectedAt[id] - w.failedAt[id]
+		}
+		if w.detectedAt[id] >= 0 {
+			msg.Detected++
+			full += w.detectedAt[id] - w.failedAt[id]
+		}
+	}
+	if suspected > 0 {
+		msg.FirstDetection = float64(first) / float64(suspected)
+	}
+	if msg.Detected > 0 {
+		msg.FullDetection = float64(full) / float64(msg.Detected)
+	}
+	return msg
+}
+
+// expired returns the nodes in view suspected since round or earlier, in
+// order, so that confirming them does not depend on map order and runs stay
+// reproducible.
+func expired(view map[int]member, round int) []int {
+	var nodes []int
+	for node, m := range view {
+		if m.status == suspect && m.since <= round {
+			nodes = append(nodes, node)
+		}
+	}
+	slices.Sort(nodes)
+	return nodes
+}
@@ -0,0 +1,361 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// topologies nodes can be linked in, starting with the default one, under
+// which any node can reach any other.
+var topologies = []string{"complete", "geometric", "grid", "torus", "ring", "small world", "scale-free", "communities"}
+
+const (
+	defaultDegree = 4    // mean degree of a topology, if none is given
+	rewireChance  = 0.1  // probability that a small world link is rewired to a random node
+	communities   = 5    // clusters the communities topology splits the nodes into
+	bridgeChance  = 0.02 // probability that a node links to another community
+)
+
+// TopologyMsg is sent while a simulation is running whenever nodes join or
+// leave a topology, and carries every link between nodes that have not left.
+type TopologyMsg struct {
+	RunID int
+	Round int
+	Edges []Edge
+}
+
+// Topologies returns the names of every topology, starting with the default
+// one.
+func Topologies() []string {
+	return slices.Clone(topologies)
+}
+
+// WithTopology restricts gossip to the links of a graph, generated over the
+// node layout once the nodes are placed:
+//
+//   - "complete" links every node to every other, as if there was no graph.
+//   - "geometric" links nodes within a radius of param cells of each other.
+//   - "grid" lays the nodes out on a lattice and links each to the nodes
+//     beside it, which "torus" wraps around the edges.
+//   - "ring" links each node to the param nodes closest to it around a ring.
+//   - "small world" is a ring that rewires some links to random nodes, after
+//     Watts and Strogatz.
+//   - "scale-free" attaches each node to param/2 nodes, preferring those with
+//     the most links, after Barabási and Albert.
+//   - "communities" splits the nodes into spatial clusters linked densely
+//     inside and sparsely between them.
+//
+// param is the radius of a geometric graph, and the mean degree of a ring,
+// small world, scale-free or communities graph. A param of 0 picks a mean
+// degree of about 4, or a radius that keeps a geometric graph connected.
+// Grid and torus nodes always have four links.
+// Nodes that join later link to the nodes within the radius, to param/2
+// nodes preferring the most linked under scale-free, or else to the param
+// nodes closest to them.
+func WithTopology(name string, param float64) Option {
+	return func(s *Simulation) {
+		s.topology = name
+		s.topologyParam = param
+	}
+}
+
+// Topology returns the links of the topology between nodes that have not
+// left, each once, or none under the complete topology.
+func (s *Simulation) Topology() []Edge {
+	var edges []Edge
+	for id, peers := range s.graph {
+		if s.position[id] < 0 {
+			continue
+		}
+		for _, peer := range peers {
+			if peer > id && s.position[peer] >= 0 {
+				edges = append(edges, Edge{From: s.coord(id), To: s.coord(peer)})
+			}
+		}
+	}
+	return edges
+}
+
+// Components returns how many connected components the nodes that have not
+// left make up. A rumour cannot cross from one to another, so there is only
+// one if the topology lets every node be reached.
+func (s *Simulation) Components() int {
+	if s.graph == nil {
+		return min(len(s.present), 1)
+	}
+
+	seen := make([]bool, len(s.nodes))
+	var count int
+	for _, id := range s.present {
+		if seen[id] {
+			continue
+		}
+		count++
+		seen[id] = true
+		queue := []int{id}
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+			for _, peer := range s.graph[next] {
+				if !seen[peer] && s.position[peer] >= 0 {
+					seen[peer] = true
+					queue = append(queue, peer)
+				}
+			}
+		}
+	}
+	return count
+}
+
+// linked reports whether the topology lets from and to contact each other.
+func (s *Simulation) linked(from, to int) bool {
+	return s.graph == nil || slices.Contains(s.graph[from], to)
+}
+
+// neighbours returns the nodes linked to id that have not left.
+func (s *Simulation) neighbours(id int) []int {
+	peers := make([]int, 0, len(s.graph[id]))
+	for _, peer := range s.graph[id] {
+		if s.position[peer] >= 0 {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// connect generates the links of the topology over the nodes.
+func (s *Simulation) connect() error {
+	if s.topology == "" {
+		s.topology = topologies[0]
+	}
+	if !slices.Contains(topologies, s.topology) {
+		return fmt.Errorf("engine: unknown topology %q", s.topology)
+	}
+	if s.topology == "complete" {
+		return nil
+	}
+
+	s.graph = make([][]int, len(s.nodes))
+	degree := s.degree()
+	switch s.topology {
+	case "geometric":
+		s.geometric(s.radius())
+	case "grid", "torus":
+		s.lattice(s.topology == "torus")
+	case "ring", "small world":
+		ring := s.byAngle()
+		for i, id := range ring {
+			for j := 1; j <= max(degree/2, 1); j++ {
+				peer := ring[(i+j)%len(ring)]
+				if s.topology == "small world" && s.rand.Float64() < rewireChance {
+					peer = s.rand.Intn(len(s.nodes))
+				}
+				s.link(id, peer)
+			}
+		}
+	case "scale-free":
+		var ends []int // one entry per link end, so nodes are picked in proportion to their degree
+		for id := range s.nodes {
+			ends = s.attach(id, ends)
+		}
+		s.ends = ends
+	case "communities":
+		s.cluster(degree)
+	}
+	return nil
+}
+
+// degree returns the mean degree of the topology.
+func (s *Simulation) degree() int {
+	if s.topologyParam <= 0 {
+		return defaultDegree
+	}
+	return max(int(math.Round(s.topologyParam)), 1)
+}
+
+// radius returns the radius of a geometric graph. If none was given it picks
+// one giving a mean degree of twice the log of the node count, above which a
+// random geometric graph is almost always connected.
+func (s *Simulation) radius() float64 {
+	if s.topologyParam > 0 {
+		return s.topologyParam
+	}
+	n := float64(max(len(s.nodes), 2))
+	degree := max(2*math.Log(n), defaultDegree)
+	return math.Sqrt(degree * float64(s.width*s.height) / (math.Pi * n))
+}
+
+// link links a and b, unless they are the same node or already linked.
+func (s *Simulation) link(a, b int) {
+	if a == b || slices.Contains(s.graph[a], b) {
+		return
+	}
+	s.graph[a] = append(s.graph[a], b)
+	s.graph[b] = append(s.graph[b], a)
+}
+
+// distance returns the distance between a and b.
+func (s *Simulation) distance(a, b int) float64 {
+	return math.Hypot(float64(s.nodes[a].X-s.nodes[b].X), float64(s.nodes[a].Y-s.nodes[b].Y))
+}
+
+// geometric links every pair of nodes within radius of each other. Nodes are
+// bucketed into cells radius wide, so only neighbouring buckets are checked.
+func (s *Simulation) geometric(radius float64) {
+	size := max(int(math.Ceil(radius)), 1)
+	buckets := make(map[[2]int][]int)
+	for id, n := range s.nodes {
+		bucket := [2]int{n.X / size, n.Y / size}
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, peer := range buckets[[2]int{bucket[0] + dx, bucket[1] + dy}] {
+					if s.distance(id, peer) <= radius {
+						s.link(id, peer)
+					}
+				}
+			}
+		}
+		buckets[bucket] = append(buckets[bucket], id)
+	}
+}
+
+// lattice moves the nodes onto a lattice spread evenly over the grid, filled
+// row by row, and links each node to the ones beside it. With wrap set the
+// rows and columns wrap around, making a torus.
+func (s *Simulation) lattice(wrap bool) {
+	n := len(s.nodes)
+	if n == 0 {
+		return
+	}
+	cols := int(math.Ceil(math.Sqrt(float64(n * s.width / max(s.height, 1)))))
+	cols = min(max(cols, (n+s.height-1)/s.height, 1), s.width)
+	rows := (n + cols - 1) / cols
+
+	clear(s.nodeMap)
+	for id := range s.nodes {
+		row, col := id/cols, id%cols
+		s.nodes[id] = Node{X: col * s.width / cols, Y: row * s.height / rows}
+		s.nodeMap[s.coord(id)] = id
+	}
+
+	width := func(row int) int { return min(cols, n-row*cols) }
+	for id := range s.nodes {
+		row, col := id/cols, id%cols
+		if col+1 < width(row) {
+			s.link(id, id+1)
+		} else if wrap {
+			s.link(id, row*cols)
+		}
+		if id+cols < n {
+			s.link(id, id+cols)
+		} else if wrap {
+			s.link(id, col)
+		}
+	}
+}
+
+// byAngle returns the nodes in order of their angle around the centre of
+// the grid, so that neighbours around a ring are close on the grid too.
+func (s *Simulation) byAngle() []int {
+	cx, cy := float64(s.width)/2, float64(s.height)/2
+	ring := make([]int, len(s.nodes))
+	for i := range ring {
+		ring[i] = i
+	}
+	slices.SortStableFunc(ring, func(a, b int) int {
+		angleA := math.Atan2(float64(s.nodes[a].Y)-cy, float64(s.nodes[a].X)-cx)
+		angleB := math.Atan2(float64(s.nodes[b].Y)-cy, float64(s.nodes[b].X)-cx)
+		return cmp.Compare(angleA, angleB)
+	})
+	return ring
+}
+
+// attach links id to degree/2 distinct nodes picked in proportion to their
+// degree from ends, or to every node before it if it is among the first few,
+// and returns ends with the new links added.
+func (s *Simulation) attach(id int, ends []int) []int {
+	m := max(s.degree()/2, 1)
+	if id <= m || len(ends) == 0 {
+		for peer := range id {
+			if s.position[peer] >= 0 {
+				s.link(id, peer)
+				ends = append(ends, id, peer)
+			}
+		}
+		return ends
+	}
+
+	// nodes that left stay in ends, so give up after enough misses
+	for picked, tries := 0, 0; picked < m && tries < 100*m; tries++ {
+		peer := ends[s.rand.Intn(len(ends))]
+		if peer == id || slices.Contains(s.graph[id], peer) || s.position[peer] < 0 {
+			continue
+		}
+		s.link(id, peer)
+		ends = append(ends, id, peer)
+		picked++
+	}
+	return ends
+}
+
+// cluster splits the nodes into communities around random centres, each node
+// joining the closest, then links each node to degree/2 random members of
+// its own community and, now and then, to a node of another.
+func (s *Simulation) cluster(degree int) {
+	if len(s.nodes) == 0 {
+		return
+	}
+	centres := s.rand.Perm(len(s.nodes))[:min(communities, len(s.nodes))]
+	members := make([][]int, len(centres))
+	community := make([]int, len(s.nodes))
+	for id := range s.nodes {
+		for i, centre := range centres {
+			if s.distance(id, centre) < s.distance(id, centres[community[id]]) {
+				community[id] = i
+			}
+		}
+		members[community[id]] = append(members[community[id]], id)
+	}
+
+	for id := range s.nodes {
+		own := members[community[id]]
+		for range max(degree/2, 1) {
+			s.link(id, own[s.rand.Intn(len(own))])
+		}
+		if len(centres) > 1 && s.rand.Float64() < bridgeChance {
+			other := members[(community[id]+1+s.rand.Intn(len(centres)-1))%len(centres)]
+			s.link(id, other[s.rand.Intn(len(other))])
+		}
+	}
+}
+
+// joinTopology links a node that just joined into the topology.
+func (s *Simulation) joinTopology(id int) {
+	if s.graph == nil {
+		return
+	}
+	s.graph = append(s.graph, nil)
+	s.regraphed = true
+
+	switch s.topology {
+	case "geometric":
+		radius := s.radius()
+		for _, peer := range s.present {
+			if peer != id && s.distance(id, peer) <= radius {
+				s.link(id, peer)
+			}
+		}
+	case "scale-free":
+		s.ends = s.attach(id, s.ends)
+	default:
+		closest := slices.DeleteFunc(slices.Clone(s.present), func(peer int) bool { return peer == id })
+		slices.SortFunc(closest, func(a, b int) int {
+			return cmp.Compare(s.distance(id, a), s.distance(id, b))
+		})
+		for _, peer := range closest[:min(s.degree(), len(closest))] {
+			s.link(id, peer)
+		}
+	}
+}
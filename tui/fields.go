@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// field is one of the setup inputs shown in the controls. Each program step
+// before the nodes are loaded focuses one field.
+type field interface {
+	Focus() tea.Cmd
+	Blur()
+	Reset()
+	Update(message tea.Msg) tea.Cmd
+	Value() string
+	View() string
+}
+
+// numberField is a text input that only accepts digits, and any of its
+// symbols. Reset returns it to its initial value.
+type numberField struct {
+	textinput.Model
+	initial, symbols string
+}
+
+func newNumberField(placeholder string) *numberField {
+	f := &numberField{Model: textinput.New()}
+	f.Placeholder = placeholder
+	return f
+}
+
+// accepting lets the field take symbols as well as digits.
+func (f *numberField) accepting(symbols string) *numberField {
+	f.symbols = symbols
+	return f
+}
+
+func (f *numberField) Update(message tea.Msg) tea.Cmd {
+	switch msg := message.(type) {
+	case tea.KeyMsg:
+		_, err := strconv.Atoi(msg.String())
+		if err != nil && msg.String() != "backspace" && !strings.Contains(f.symbols, msg.String()) {
+			return nil
+		}
+
+	}
+
+	var cmd tea.Cmd
+	f.Model, cmd = f.Model.Update(message)
+	return cmd
+}
+
+// picker lets the user cycle through a fixed set of options with the arrow
+// keys.
+type picker struct {
+	options []string
+	index   int
+	focused bool
+}
+
+func newPicker(options []string) *picker {
+	return &picker{options: options}
+}
+
+func (p *picker) Update(message tea.Msg) tea.Cmd {
+	if !p.focused {
+		return nil
+	}
+	msg, ok := message.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+	switch msg.String() {
+	case "left":
+		p.index = (p.index + len(p.options) - 1) % len(p.options)
+	case "right":
+		p.index = (p.index + 1) % len(p.options)
+	}
+	return nil
+}
+
+func (p *picker) Value() string {
+	return p.options[p.index]
+}
+
+func (p *picker) Focus() tea.Cmd {
+	p.focused = true
+	return nil
+}
+
+func (p *picker) Blur() {
+	p.focused = false
+}
+
+func (p *picker) Reset() {
+	p.index = 0
+}
+
+func (p *picker) View() string {
+	if p.focused {
+		return "< " + p.Value() + " >"
+	}
+	return p.Value()
+}
+
+func (f *numberField) Reset() {
+	f.SetValue(f.initial)
+}
@@ -0,0 +1,1100 @@
+// Package tui is the bubbletea front end shared by the local app and the ssh
+// server. It collects the simulation parameters, draws the nodes and animates
+// the rumour as the engine spreads it.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/nolanjannotta/gossip-protocol-visualizer/engine"
+)
+
+const (
+	start = iota
+	nodeAmountInput
+	spreadInput
+	protocolInput
+	topologyInput
+	degreeInput
+	interestInput
+	antiEntropyInput
+	periodInput
+	failuresInput
+	lossInput
+	latencyInput
+	churnInput
+	healInput
+	byzantineInput
+	behaviourInput
+	seedInput
+	chooseStartingNode
+	simulationRunning
+)
+
+// glyphs for infected nodes, by how they were informed. Susceptible nodes
+// have a glyph of their own.
+var glyphs = map[engine.Via]string{
+	engine.Started:  "⬤",
+	engine.Pushed:   "⬤",
+	engine.Pulled:   "◉",
+	engine.Repaired: "◆",
+}
+
+// removedGlyphs for removed nodes, smaller than those of infected nodes but
+// still showing how they were informed.
+var removedGlyphs = map[engine.Via]string{
+	engine.Started:  "●",
+	engine.Pushed:   "●",
+	engine.Pulled:   "◎",
+	engine.Repaired: "◇",
+}
+
+const (
+	susceptibleGlyph = "◯"
+	crashedGlyph     = "✕"
+	lostGlyph        = "◌" // flashed on a node a lost message was meant for
+	suspectedGlyph   = "◍"
+)
+
+// partition glyphs, by the direction the boundary runs through a cell.
+const (
+	horizontalGlyph = "─"
+	verticalGlyph   = "│"
+	fallingGlyph    = "╲"
+	risingGlyph     = "╱"
+)
+
+var cornerGlyphs = [2][2]string{{"┌", "└"}, {"┐", "┘"}} // by right, bottom
+
+var stateColors = map[engine.State]lipgloss.Color{
+	engine.Susceptible: "250",
+	engine.Infected:    "203",
+	engine.Removed:     "69",
+	engine.Crashed:     "240",
+}
+
+// healthColors replace the state color of nodes that other nodes suspect or
+// have confirmed dead, under a membership protocol.
+var healthColors = map[engine.Health]lipgloss.Color{
+	engine.Suspected: "220",
+	engine.Confirmed: "208",
+	engine.Detected:  "196",
+}
+
+// staleColors show how far behind a node is under a state reconciliation
+// protocol, from up to date to behind on every other node.
+var staleColors = []lipgloss.Color{"250", "224", "217", "210", "203", "196"}
+
+var antiEntropyModes = []string{"no anti-entropy", "full state", "digest"}
+
+// behaviours byzantine nodes can be given, the first mixing all of them.
+var behaviours = []string{"mixed", "drop rumours", "lie about relaying", "forge a value"}
+
+var behaviourOptions = map[string][]engine.Behaviour{
+	"drop rumours":       {engine.Dropping},
+	"lie about relaying": {engine.Lying},
+	"forge a value":      {engine.Forging},
+}
+
+// rumourColors replace the state color of informed nodes once more than one
+// rumour is spreading, by the rumour a node learned last. They are reused if
+// there are more rumours than colors.
+var rumourColors = []lipgloss.Color{"203", "75", "114", "221", "177", "44", "209", "147"}
+
+// forgedColor replaces the state color of nodes holding the forged value.
+const forgedColor = lipgloss.Color("201")
+
+// speeds a running simulation can be played at, in rounds per second. 0
+// runs it as fast as possible.
+var speeds = []float64{1, 2, 5, 10, 20, 50, 0}
+
+// inputsPerRow is how many setup inputs fit side by side in the controls.
+const inputsPerRow = 4
+
+type styles struct {
+	border, nodesStyle, controls, inputStyle, directionStyle lipgloss.Style
+	states                                                   map[engine.State]lipgloss.Style
+	stale                                                    []lipgloss.Style
+	health                                                   map[engine.Health]lipgloss.Style
+	lost, forged                                             lipgloss.Style
+	rumours                                                  []lipgloss.Style
+	partition, drawing, link, tree, edge, trail              lipgloss.Style
+	converged, diverged                                      lipgloss.Style
+}
+
+type model struct {
+	*program
+	renderer                   *lipgloss.Renderer
+	width, height, programStep int
+	inputs                     []field // one per setup step, in order
+	directions                 []string
+	extraMessage, screenOutput string
+	warning                    string // shown above the directions, like when the topology is disconnected
+	simulation                 *engine.Simulation
+	pixelMap                   map[[2]int]string
+	flashes                    map[[2]int]string // drawn over pixelMap until the next RelayMsg
+	flashLost                  bool
+	rumours                    int                    // rumours started
+	held                       map[[2]int]uint64      // rumours each node holds
+	latest                     map[[2]int]int         // rumour each node learned last
+	coverage                   []int                  // live nodes holding each rumour
+	membership                 *engine.MembershipMsg  // latest, under a membership protocol
+	broadcast                  *engine.BroadcastMsg   // latest, under a tree-based broadcast protocol
+	reconcile                  *engine.ReconcileMsg   // latest, under a state reconciliation protocol
+	convergence                *engine.ConvergenceMsg // latest, under a CRDT protocol
+	partitions                 []engine.Partition
+	fences                     map[[2]int]string // partition boundaries, drawn on empty cells
+	links                      map[[2]int]string // overlay links, drawn on empty cells under the fences
+	edges                      map[[2]int]string // topology links, drawn faintly under the overlay links
+	relays                     []relay           // relays being animated, oldest first
+	relayCells                 map[[2]int]string // the relays, drawn on empty cells over the fences
+	animating                  bool              // a frameMsg is due
+	drawFrom, drawTo           *[2]int           // ends of the partition being drawn with the mouse
+	drawButton                 tea.MouseButton   // button the partition is being drawn with
+	drawRect                   bool
+	heal                       int // rounds a drawn partition lasts, 0 for ever
+	canvasWidth, canvasHeight  int
+	nodeCount, spread          int
+	interest, period           int
+	seed                       *int64 // nil for a random seed
+	ctx                        context.Context
+	cancel                     context.CancelFunc // stops the current run
+	runID                      int                // messages from any other run are stale
+	running                    bool
+	round, speed               int // speed indexes speeds
+	loss, byzantine            float64
+	styles                     styles
+	hasError                   bool
+}
+
+type program struct {
+	program *tea.Program
+}
+
+// Send hands a message from a running simulation to the program, so that
+// the program can serve as its engine.Sender.
+func (p *program) Send(msg any) {
+	p.program.Send(msg)
+}
+
+// Config holds the settings a visualizer starts with.
+type Config struct {
+	// Renderer renders every style. The default renderer is used if it is
+	// nil.
+	Renderer *lipgloss.Renderer
+	// Seed pre-fills the seed input, if it is not empty.
+	Seed string
+	// Context bounds every simulation the visualizer runs, such as the
+	// lifetime of an ssh session. It defaults to context.Background.
+	Context context.Context
+}
+
+// NewProgram creates a bubbletea program running the visualizer.
+func NewProgram(cfg Config, opts ...tea.ProgramOption) *tea.Program {
+	p := &program{}
+
+	if cfg.Renderer == nil {
+		cfg.Renderer = lipgloss.DefaultRenderer()
+	}
+	if cfg.Context == nil {
+		cfg.Context = context.Background()
+	}
+
+	m := model{}
+	m.program = p
+	m.renderer = cfg.Renderer
+	m.ctx = cfg.Context
+	m.initializeModel()
+	m.inputs[seedInput-1].(*numberField).initial = cfg.Seed
+	m.inputs[seedInput-1].Reset()
+
+	p.program = tea.NewProgram(m, opts...)
+
+	return p.program
+}
+
+func (m *model) initializeModel() {
+
+	m.styles.states = make(map[engine.State]lipgloss.Style)
+	for state, color := range stateColors {
+		m.styles.states[state] = m.renderer.NewStyle().Foreground(color)
+	}
+
+	m.styles.health = make(map[engine.Health]lipgloss.Style)
+	for health, color := range healthColors {
+		m.styles.health[health] = m.renderer.NewStyle().Foreground(color)
+	}
+
+	m.styles.stale = nil
+	for _, color := range staleColors {
+		m.styles.stale = append(m.styles.stale, m.renderer.NewStyle().Foreground(color))
+	}
+
+	m.styles.lost = m.renderer.NewStyle().Foreground(lipgloss.Color("214"))
+	m.styles.forged = m.renderer.NewStyle().Foreground(forgedColor)
+	m.styles.rumours = nil
+	for _, color := range rumourColors {
+		m.styles.rumours = append(m.styles.rumours, m.renderer.NewStyle().Foreground(color))
+	}
+	m.styles.partition = m.renderer.NewStyle().Foreground(lipgloss.Color("220"))
+	m.styles.drawing = m.renderer.NewStyle().Foreground(lipgloss.Color("240"))
+	m.styles.converged = m.renderer.NewStyle().Foreground(lipgloss.Color("114"))
+	m.styles.diverged = m.renderer.NewStyle().Foreground(lipgloss.Color("208"))
+	m.styles.link = m.renderer.NewStyle().Foreground(lipgloss.Color("237"))
+	m.styles.tree = m.renderer.NewStyle().Foreground(lipgloss.Color("30"))
+	m.styles.edge = m.renderer.NewStyle().Foreground(lipgloss.Color("235"))
+	m.styles.trail = m.renderer.NewStyle().Foreground(lipgloss.Color("241"))
+
+	m.inputs = []field{
+		nodeAmountInput - 1:  newNumberField("Number of nodes"),
+		spreadInput - 1:      newNumberField("spread"),
+		protocolInput - 1:    newPicker(engine.Protocols()),
+		topologyInput - 1:    newPicker(engine.Topologies()),
+		degreeInput - 1:      newNumberField("degree or radius").accepting("."),
+		interestInput - 1:    newNumberField("k (interest)"),
+		antiEntropyInput - 1: newPicker(antiEntropyModes),
+		periodInput - 1:      newNumberField("anti-entropy period"),
+		failuresInput - 1:    newNumberField("failed nodes").accepting("%@"),
+		lossInput - 1:        newNumberField("message loss %").accepting("."),
+		latencyInput - 1:     newNumberField("latency ms/cell").accepting(".~"),
+		churnInput - 1:       newNumberField("churn join/leave").accepting("./"),
+		healInput - 1:        newNumberField("partitions heal after"),
+		byzantineInput - 1:   newNumberField("byzantine nodes %").accepting("."),
+		behaviourInput - 1:   newPicker(behaviours),
+		seedInput - 1:        newNumberField("seed (random)"),
+	}
+	m.directions = []string{
+		"> press enter to start new simulation.\n> press ctrl+c to quit.",
+		"> choose the number of nodes.\n> the press enter",
+		"> choose the spread amount. hyparview and plumtree nodes keep one more active peer than it, and at least four.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose the protocol with the arrow keys.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose the topology nodes gossip over with the arrow keys. complete lets any node contact any other.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose the mean degree of the topology, or the radius in cells of a geometric one. grid and torus ignore it.\n> press enter to continue, or leave it empty for a degree of about 4.",
+		"> choose k, how long rumour mongering nodes stay interested, or how many helpers a swim node asks to ping for it.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose how nodes reconcile with anti-entropy, using the arrow keys.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose how many rounds pass between anti-entropy reconciliations.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose how many nodes crash, as a count or a percentage like 10%. add @n to crash them in round n.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose the percentage of messages the network loses.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose the latency in ms per cell of distance, optionally ~ a jitter in ms, like 5~20.\n> press enter to continue, or leave it empty for lock-step rounds.",
+		"> choose how many nodes join and leave each round, like 2/1. fractions join or leave one node that often.\n> press enter to continue, or leave it empty for a fixed set of nodes.",
+		"> choose how many rounds a partition drawn on the canvas lasts before it heals.\n> press enter to continue, or leave it empty for partitions that never heal.",
+		"> choose the percentage of nodes that misbehave.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose how misbehaving nodes treat the rumour with the arrow keys. forged values are shown in magenta.\n> press enter to continue. press ctrl+z for previous input.",
+		"> choose a seed to reproduce a previous run, or leave it empty for a random one.\n> press enter to load simulation. press ctrl+z for previous input.",
+		"> simulation loaded.\n> Click on one or more starting nodes, each starting its own rumour, then press enter to start simulation. swim, scuttlebutt and the crdt protocols need no starting node. right-drag to draw a partition, b to switch line/rectangle.",
+		"> simulation is running..."}
+	m.programStep = 0
+	m.speed = len(speeds) - 1
+	m.flashLost = true
+
+	m.styles.border = m.renderer.NewStyle()
+	m.styles.inputStyle = m.renderer.NewStyle().Border(lipgloss.NormalBorder()).Align(lipgloss.Left).Width(25).Height(1).MarginLeft(1)
+}
+
+func (m model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := message.(type) {
+	case engine.SimulationStatusMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		if msg.Done {
+
+			var simulated string
+			if msg.SimTime > 0 {
+				simulated = fmt.Sprintf(", %s simulated", msg.SimTime.Round(time.Millisecond))
+			}
+			// protocols that spread no rumour have nobody to inform
+			var informed string
+			if m.simulation.NeedsStart() {
+				live := msg.Nodes - msg.Crashed
+				coverage := float64(msg.Informed) / float64(max(live, 1)) * 100
+				informed = fmt.Sprintf(" %d of %d live nodes informed, coverage %.1f%%, residue %.1f%%,", msg.Informed, live, coverage, 100-coverage)
+			}
+			m.extraMessage = fmt.Sprintf("> simulation finished in %d iterations%s and took %s. %d nodes present, %d joined, %d left.%s %d crashed, %d byzantine, %d honest nodes hold a forged value.\n> %d messages delivered, %d lost, %d cut by partitions, %d redundant. seed %d. press ctrl+x to reset.", msg.Iteration, simulated, msg.Time, msg.Nodes, msg.Joined, msg.Left, informed, msg.Crashed, msg.Byzantine, msg.Misled, msg.Messages-msg.Lost-msg.Partitioned, msg.Lost, msg.Partitioned, msg.Redundant, msg.Seed)
+			if m.rumours > 1 {
+				m.extraMessage += "\n> coverage by rumour: " + m.rumourCoverage(msg.Coverage)
+			}
+			if m.membership != nil {
+				m.extraMessage += "\n> " + m.detection()
+			}
+			if m.broadcast != nil {
+				m.extraMessage += "\n> " + m.broadcastCounts()
+			}
+			if m.reconcile != nil {
+				m.extraMessage += "\n> " + m.transferred()
+			}
+			if m.convergence != nil {
+				m.extraMessage += "\n> " + m.converged()
+			}
+			m.programStep++
+
+			clear(m.flashes)
+			m.drawFrom, m.drawTo = nil, nil
+			m.loadFences()
+			m.drawPixels()
+		}
+
+	case engine.MembershipMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.membership = &msg
+		return m, nil
+
+	case engine.ReconcileMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.reconcile = &msg
+		for coord, stale := range msg.Stale {
+			m.pixelMap[coord] = m.staleGlyph(stale)
+		}
+		m.drawPixels()
+		return m, nil
+
+	case engine.ConvergenceMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.convergence = &msg
+		for coord, matches := range msg.Matches {
+			m.pixelMap[coord] = m.convergedGlyph(matches)
+		}
+		if len(msg.Matches) > 0 {
+			m.drawPixels()
+		}
+		return m, nil
+
+	case engine.BroadcastMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.broadcast = &msg
+		return m, nil
+
+	case engine.OverlayMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.loadLinks(msg.Edges)
+		m.drawPixels()
+		return m, nil
+
+	case engine.TopologyMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.loadEdges(msg.Edges)
+		m.drawPixels()
+		return m, nil
+
+	case frameMsg:
+		if msg.runID != m.runID {
+			return m, nil
+		}
+		cmd := m.animate()
+		return m, cmd
+
+	case engine.PartitionMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.partitions = msg.Partitions
+		m.loadFences()
+		m.drawPixels()
+		return m, nil
+
+	case engine.RelayMsg:
+		if msg.RunID != m.runID {
+			return m, nil
+		}
+		m.round = msg.Round
+		for _, change := range msg.Changes {
+			if change.State == engine.Departed {
+				// the position is free for a node to join at
+				delete(m.held, change.Coord)
+				delete(m.latest, change.Coord)
+			}
+			if news := change.Rumours &^ m.held[change.Coord]; news != 0 {
+				m.latest[change.Coord] = bits.TrailingZeros64(news)
+			}
+			m.held[change.Coord] = change.Rumours
+			m.pixelMap[change.Coord] = m.glyph(change.State, change.Via, change.Forged, m.latest[change.Coord])
+			if change.Health != engine.Alive {
+				m.pixelMap[change.Coord] = m.healthGlyph(change.Health)
+			}
+			if change.Relayed {
+				m.addRelay(change.From, change.Coord)
+			}
+
+		}
+		if msg.Coverage != nil {
+			m.coverage = msg.Coverage
+		}
+
+		clear(m.flashes)
+		if m.flashLost {
+			for _, coord := range msg.Lost {
+				m.flashes[coord] = m.styles.lost.Render(lostGlyph)
+			}
+		}
+
+		m.loadRelays()
+		m.drawPixels()
+		cmd := m.startAnimation()
+		return m, cmd
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			m.stop()
+			return m, tea.Quit
+
+		case "enter", "ctrl+z":
+			if m.programStep >= start && m.programStep <= len(m.inputs) && msg.String() == "ctrl+z" {
+				m.programStep--
+			}
+			if msg.String() == "enter" && m.programStep < simulationRunning {
+				m.programStep++
+
+			}
+			m.nodeCount = m.number(nodeAmountInput)
+			m.spread = m.number(spreadInput)
+			m.interest = m.number(interestInput)
+			m.period = m.number(periodInput)
+			m.loss, _ = strconv.ParseFloat(m.inputs[lossInput-1].Value(), 64)
+			m.heal = m.number(healInput)
+			m.byzantine, _ = strconv.ParseFloat(m.inputs[byzantineInput-1].Value(), 64)
+			m.seed = nil
+			if seed, err := strconv.ParseInt(m.inputs[seedInput-1].Value(), 10, 64); err == nil {
+				m.seed = &seed
+			}
+
+			cmds = append(cmds, m.updateProgramStep())
+
+		case "ctrl+x":
+			cmds = append(cmds, m.reset())
+
+		case " ", "s", "+", "=", "-", "f":
+			if m.programStep == simulationRunning && m.running {
+				m.control(msg.String())
+			}
+
+		case "b":
+			if m.partitioning() {
+				m.drawRect = !m.drawRect
+				m.loadFences()
+				m.drawPixels()
+			}
+		}
+	case tea.MouseMsg:
+
+		if m.partitioning() && m.drawPartition(msg) {
+			return m, nil
+		}
+
+		if m.programStep == chooseStartingNode && msg.String() == "left press" && m.simulation != nil && m.simulation.NeedsStart() {
+
+			nodeX, nodeY := msg.X-2, msg.Y-3 // substracting offset
+
+			key := [2]int{nodeX, nodeY}
+
+			id, ok := m.simulation.NodeAt(nodeX, nodeY)
+			if !ok || !m.simulation.Start(id) {
+				return m, nil
+			}
+
+			m.latest[key] = m.rumours
+			m.held[key] = m.simulation.Rumours(id)
+			m.rumours++
+			m.pixelMap[key] = m.glyph(engine.Infected, engine.Started, m.simulation.Forged(id), m.latest[key])
+
+			m.drawPixels()
+
+		}
+
+	case tea.WindowSizeMsg:
+
+		m.handleResize(msg)
+
+	}
+	// this handles the curser blinking, except in wish server?
+	cmds = append(cmds, m.updateInputs(message))
+	return m, tea.Batch(cmds...)
+}
+
+func (m *model) updateInputs(message tea.Msg) tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.inputs))
+
+	for i := range m.inputs {
+
+		cmds[i] = m.inputs[i].Update(message)
+
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// number returns the value of the numeric input for step, or 0 if it is
+// empty.
+func (m *model) number(step int) int {
+	n, _ := strconv.Atoi(m.inputs[step-1].Value())
+	return n
+}
+
+func (m *model) updateProgramStep() tea.Cmd {
+	var cmd tea.Cmd
+	for i := 0; i < len(m.inputs); i++ {
+		if i == m.programStep-1 { // program steps start at 1
+			cmd = m.inputs[i].Focus()
+			continue
+		}
+		m.inputs[i].Blur()
+
+	}
+	if m.programStep == chooseStartingNode {
+		m.loadBlankScreen()
+		m.loadNodes()
+		m.drawPixels()
+		return cmd
+	}
+	if m.programStep == simulationRunning && m.simulation != nil && !m.running {
+		if m.simulation.NeedsStart() && !m.simulation.Started() {
+			m.programStep = chooseStartingNode
+			return cmd
+		}
+
+		m.running = true
+		m.simulation.SetSpeed(speeds[m.speed])
+
+		ctx, cancel := context.WithCancel(m.ctx)
+		m.cancel = cancel
+		m.runID++
+		simulation, id, program := m.simulation, m.runID, m.program
+		go simulation.Run(ctx, id, program)
+
+		return cmd
+	}
+
+	return cmd
+}
+
+// stop cancels the current run, if there is one, and makes any message it
+// already sent stale.
+func (m *model) stop() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.runID++
+}
+
+func (m *model) reset() tea.Cmd {
+	var cmd tea.Cmd
+
+	m.stop()
+
+	m.programStep = 1
+	m.screenOutput = ""
+	m.extraMessage = ""
+	m.warning = ""
+	m.hasError = false
+	m.simulation = nil
+	m.pixelMap = nil
+	m.flashes = nil
+	m.rumours = 0
+	m.held = nil
+	m.latest = nil
+	m.coverage = nil
+	m.membership = nil
+	m.broadcast = nil
+	m.reconcile = nil
+	m.convergence = nil
+	m.partitions = nil
+	m.fences = nil
+	m.links = nil
+	m.edges = nil
+	m.relays = nil
+	m.relayCells = nil
+	m.animating = false
+	m.drawFrom, m.drawTo = nil, nil
+	m.drawRect = false
+	m.running = false
+	m.round = 0
+	for i := range m.inputs {
+		m.inputs[i].Reset()
+	}
+
+	for i := 0; i < len(m.inputs); i++ {
+		if i == m.programStep-1 { // program steps start at 1
+			cmd = m.inputs[i].Focus()
+			continue
+		}
+		m.inputs[i].Blur()
+
+	}
+
+	return cmd
+
+}
+
+func (m *model) drawPixels() {
+	if m.hasError {
+		return
+	}
+	var screen strings.Builder
+
+	for y := 0; y < m.canvasHeight; y++ {
+		for x := 0; x < m.canvasWidth; x++ {
+			pixel, flashed := m.flashes[[2]int{x, y}]
+			if !flashed {
+				pixel = m.pixelMap[[2]int{x, y}]
+			}
+			if relay, ok := m.relayCells[[2]int{x, y}]; ok && pixel == " " {
+				pixel = relay
+			}
+			if fence, ok := m.fences[[2]int{x, y}]; ok && pixel == " " {
+				pixel = fence
+			}
+			if link, ok := m.links[[2]int{x, y}]; ok && pixel == " " {
+				pixel = link
+			}
+			if edge, ok := m.edges[[2]int{x, y}]; ok && pixel == " " {
+				pixel = edge
+			}
+			screen.WriteString(pixel)
+		}
+		if y < m.canvasHeight-1 {
+			screen.WriteString("\n")
+		}
+
+	}
+	m.screenOutput = screen.String()
+
+}
+
+func (m *model) loadBlankScreen() {
+	if m.hasError {
+		return
+	}
+
+	m.pixelMap = make(map[[2]int]string)
+	m.flashes = make(map[[2]int]string)
+	m.held = make(map[[2]int]uint64)
+	m.latest = make(map[[2]int]int)
+
+	m.canvasHeight = m.styles.nodesStyle.GetHeight()
+	m.canvasWidth = m.styles.nodesStyle.GetWidth()
+
+	max := m.canvasHeight * m.canvasWidth
+	if m.nodeCount > max {
+		m.extraMessage = fmt.Sprintf("> too many nodes. Please enter %d or less\n> press ctrl+x", max)
+		m.hasError = true
+		return
+	}
+	for y := 0; y < m.canvasHeight; y++ {
+		for x := 0; x < m.canvasWidth; x++ {
+			m.pixelMap[[2]int{x, y}] = " "
+		}
+	}
+
+}
+
+func (m *model) loadNodes() {
+	if m.hasError {
+		return
+	}
+	if m.simulation != nil {
+		return
+	}
+
+	degree, _ := strconv.ParseFloat(m.inputs[degreeInput-1].Value(), 64)
+	opts := []engine.Option{
+		engine.WithSize(m.canvasWidth, m.canvasHeight),
+		engine.WithNodes(m.nodeCount),
+		engine.WithSpread(m.spread),
+		engine.WithProtocol(m.inputs[protocolInput-1].Value()),
+		engine.WithTopology(m.inputs[topologyInput-1].Value(), degree),
+		engine.WithInterest(m.interest),
+		engine.WithLoss(m.loss / 100),
+		engine.WithByzantine(m.byzantine/100, behaviourOptions[m.inputs[behaviourInput-1].Value()]...),
+		m.antiEntropy(),
+	}
+	crashes, err := m.crashes()
+	if err != nil {
+		m.extraMessage = fmt.Sprintf("> %s\n> press ctrl+x", err)
+		m.hasError = true
+		return
+	}
+	latency, err := m.latency()
+	if err != nil {
+		m.extraMessage = fmt.Sprintf("> %s\n> press ctrl+x", err)
+		m.hasError = true
+		return
+	}
+	churn, err := m.churn()
+	if err != nil {
+		m.extraMessage = fmt.Sprintf("> %s\n> press ctrl+x", err)
+		m.hasError = true
+		return
+	}
+	opts = append(opts, crashes, latency, churn)
+	if m.seed != nil {
+		opts = append(opts, engine.WithSeed(*m.seed))
+	}
+
+	simulation, err := engine.New(opts...)
+	if err != nil {
+		m.extraMessage = fmt.Sprintf("> %s\n> press ctrl+x", err)
+		m.hasError = true
+		return
+	}
+
+	for id, node := range simulation.Nodes() {
+		m.pixelMap[[2]int{node.X, node.Y}] = m.glyph(simulation.State(id), engine.Started, false, 0)
+	}
+	m.simulation = simulation
+	m.loadLinks(simulation.Overlay())
+	m.loadEdges(simulation.Topology())
+	if components := simulation.Components(); components > 1 {
+		m.warning = fmt.Sprintf("> warning: the %s topology is disconnected into %d components, so no rumour can reach every node.", m.inputs[topologyInput-1].Value(), components)
+	}
+}
+
+// control pauses, steps or changes the speed of the running simulation.
+func (m *model) control(key string) {
+	switch key {
+	case " ":
+		if m.simulation.Paused() {
+			m.simulation.Resume()
+		} else {
+			m.simulation.Pause()
+		}
+	case "s":
+		m.simulation.Step()
+	case "+", "=":
+		m.speed = min(m.speed+1, len(speeds)-1)
+		m.simulation.SetSpeed(speeds[m.speed])
+	case "-":
+		m.speed = max(m.speed-1, 0)
+		m.simulation.SetSpeed(speeds[m.speed])
+	case "f":
+		m.flashLost = !m.flashLost
+	}
+}
+
+func (m *model) runningStatus() string {
+	speed := "as fast as possible"
+	if speeds[m.speed] > 0 {
+		speed = fmt.Sprintf("%g rounds/s", speeds[m.speed])
+	}
+	if m.simulation.Paused() {
+		speed = "paused"
+	}
+	click := "click a node to kill it"
+	var end string
+	if m.simulation.Updatable() {
+		click = "click a node to update it, right click to decrement or remove"
+		end = "\n> the run waits for more updates until ctrl+x resets it."
+	}
+	status := fmt.Sprintf("> simulation is running. round %d, %s.\n> space to pause, s to step, +/- to change speed, f to flash lost messages. %s, drag to draw a partition, b to switch line/rectangle.%s", m.round, speed, click, end)
+	if m.rumours > 1 {
+		status += "\n> coverage by rumour: " + m.rumourCoverage(m.coverage)
+	}
+	if m.membership != nil {
+		status += "\n> " + m.detection()
+	}
+	if m.broadcast != nil {
+		status += "\n> " + m.broadcastCounts()
+	}
+	if m.reconcile != nil {
+		status += "\n> " + m.transferred()
+	}
+	if m.convergence != nil {
+		status += "\n> " + m.converged()
+	}
+	return status
+}
+
+// detection describes how well the membership protocol is detecting the
+// nodes that crashed.
+func (m *model) detection() string {
+	msg := m.membership
+	var rate float64
+	if msg.Suspicions > 0 {
+		rate = float64(msg.FalsePositives) / float64(msg.Suspicions) * 100
+	}
+	return fmt.Sprintf("%d of %d crashed nodes detected by every live node. detection latency %.1f rounds to first suspicion, %.1f to full detection. %d of %d suspicions false positives (%.1f%%).", msg.Detected, msg.Failed, msg.FirstDetection, msg.FullDetection, msg.FalsePositives, msg.Suspicions, rate)
+}
+
+// rumourCoverage lists how many nodes hold each rumour, next to its color.
+func (m *model) rumourCoverage(coverage []int) string {
+	var counts []string
+	for rumour, count := range coverage {
+		counts = append(counts, fmt.Sprintf("%s %d", m.styles.rumours[rumour%len(m.styles.rumours)].Render(glyphs[engine.Started]), count))
+	}
+	return strings.Join(counts, "  ")
+}
+
+// glyph renders a node in state, informed via via, in the forged color if
+// it holds the forged value, or in the color of rumour, the one it learned
+// last, if more than one rumour is spreading.
+func (m *model) glyph(state engine.State, via engine.Via, forged bool, rumour int) string {
+	glyph := glyphs[via]
+	switch state {
+	case engine.Susceptible:
+		glyph = susceptibleGlyph
+	case engine.Removed:
+		glyph = removedGlyphs[via]
+	case engine.Crashed:
+		glyph = crashedGlyph
+	case engine.Departed:
+		return " "
+	}
+	if forged {
+		return m.styles.forged.Render(glyph)
+	}
+	if m.rumours > 1 && (state == engine.Infected || state == engine.Removed) {
+		return m.styles.rumours[rumour%len(m.styles.rumours)].Render(glyph)
+	}
+	return m.styles.states[state].Render(glyph)
+}
+
+// broadcastCounts compares the messages the broadcast tree took with
+// flooding the overlay.
+func (m *model) broadcastCounts() string {
+	msg := m.broadcast
+	var saved float64
+	if msg.Flooding > 0 {
+		saved = (1 - float64(msg.Payload)/float64(msg.Flooding)) * 100
+	}
+	return fmt.Sprintf("%d messages carried rumours along the tree, against %d flooding the overlay (%.1f%% fewer), plus %d announcements, grafts and prunes.", msg.Payload, msg.Flooding, saved, msg.Control)
+}
+
+// transferred compares the state the reconciliation protocol transferred
+// with exchanging the full state every time.
+func (m *model) transferred() string {
+	msg := m.reconcile
+	var saved float64
+	if msg.NaiveBytes > 0 {
+		saved = (1 - float64(msg.Bytes)/float64(msg.NaiveBytes)) * 100
+	}
+	return fmt.Sprintf("%d nodes behind. %d entries and %d bytes transferred, against %d entries and %d bytes exchanging full state (%.1f%% fewer bytes).", msg.Behind, msg.Entries, msg.Bytes, msg.NaiveEntries, msg.NaiveBytes, saved)
+}
+
+// converged describes how far the nodes are from agreeing on the value of
+// the CRDT, and how long they took to after the last update.
+func (m *model) converged() string {
+	msg := m.convergence
+	status := fmt.Sprintf("%d of %d live nodes hold the converged value %s after %d updates.", msg.Converged, msg.Nodes, msg.Value, msg.Updates)
+	switch {
+	case msg.LastUpdate < 0:
+		status += " no updates yet."
+	case msg.ConvergedIn < 0:
+		status += fmt.Sprintf(" %d rounds since the last update.", msg.Round-msg.LastUpdate)
+	default:
+		status += fmt.Sprintf(" converged %d rounds after the last update.", msg.ConvergedIn)
+	}
+	return status
+}
+
+// convergedGlyph renders a node by whether it holds the converged value.
+func (m *model) convergedGlyph(matches bool) string {
+	if matches {
+		return m.styles.converged.Render(glyphs[engine.Started])
+	}
+	return m.styles.diverged.Render(glyphs[engine.Started])
+}
+
+// staleGlyph renders a node behind on stale of the other nodes' state, more
+// intensely the further behind it is.
+func (m *model) staleGlyph(stale float64) string {
+	if stale <= 0 {
+		return m.styles.stale[0].Render(susceptibleGlyph)
+	}
+	level := 1 + int(stale*float64(len(m.styles.stale)-2)+0.5)
+	return m.styles.stale[min(level, len(m.styles.stale)-1)].Render(glyphs[engine.Started])
+}
+
+// healthGlyph renders a node that other nodes suspect, or have confirmed
+// dead.
+func (m *model) healthGlyph(health engine.Health) string {
+	if health == engine.Suspected {
+		return m.styles.health[health].Render(suspectedGlyph)
+	}
+	return m.styles.health[health].Render(crashedGlyph)
+}
+
+// crashes returns the engine option for the failed nodes input, which is a
+// count or a percentage of the nodes, optionally followed by @ and the round
+// they crash in.
+func (m *model) crashes() (engine.Option, error) {
+	value := m.inputs[failuresInput-1].Value()
+	if value == "" {
+		return engine.WithCrashes(0, 0), nil
+	}
+
+	amount, at, timed := strings.Cut(value, "@")
+	percent := strings.HasSuffix(amount, "%")
+
+	count, err := strconv.Atoi(strings.TrimSuffix(amount, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid failed nodes %q", value)
+	}
+	if percent {
+		count = m.nodeCount * count / 100
+	}
+
+	round := 0
+	if timed {
+		if round, err = strconv.Atoi(at); err != nil {
+			return nil, fmt.Errorf("invalid failed nodes %q", value)
+		}
+	}
+
+	return engine.WithCrashes(count, round), nil
+}
+
+// latency returns the engine option for the latency input, which is the
+// delay in ms per cell of distance, optionally followed by ~ and the
+// maximum jitter in ms.
+func (m *model) latency() (engine.Option, error) {
+	value := m.inputs[latencyInput-1].Value()
+	if value == "" {
+		return engine.WithLatency(0, 0), nil
+	}
+
+	perCell, jitter, jittered := strings.Cut(value, "~")
+	ms, err := strconv.ParseFloat(perCell, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latency %q", value)
+	}
+
+	var jitterMs float64
+	if jittered {
+		if jitterMs, err = strconv.ParseFloat(jitter, 64); err != nil {
+			return nil, fmt.Errorf("invalid latency %q", value)
+		}
+	}
+
+	return engine.WithLatency(time.Duration(ms*float64(time.Millisecond)), time.Duration(jitterMs*float64(time.Millisecond))), nil
+}
+
+// churn returns the engine option for the churn input, which is how many
+// nodes join each round, optionally followed by / and how many leave.
+func (m *model) churn() (engine.Option, error) {
+	value := m.inputs[churnInput-1].Value()
+	if value == "" {
+		return engine.WithChurn(0, 0), nil
+	}
+
+	joins, leaves, leaving := strings.Cut(value, "/")
+	join, err := strconv.ParseFloat(joins, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid churn %q", value)
+	}
+
+	var leave float64
+	if leaving {
+		if leave, err = strconv.ParseFloat(leaves, 64); err != nil {
+			return nil, fmt.Errorf("invalid churn %q", value)
+		}
+	}
+
+	return engine.WithChurn(join, leave), nil
+}
+
+// antiEntropy returns the engine option for the chosen anti-entropy mode. The
+// period defaults to every round once a mode is picked.
+func (m *model) antiEntropy() engine.Option {
+	mode := m.inputs[antiEntropyInput-1].Value()
+	if mode == antiEntropyModes[0] {
+		return engine.WithAntiEntropy(0, false)
+	}
+	return engine.WithAntiEntropy(max(m.period, 1), mode == "digest")
+}
+
+func (m *model) handleResize(msg tea.WindowSizeMsg) {
+	m.width = msg.Width
+	m.height = msg.Height
+
+	rows := (len(m.inputs) + inputsPerRow - 1) / inputsPerRow
+	controlsHeight := max(4, rows*3)
+	nodeHeight := m.height - controlsHeight - 7
+
+	border := m.renderer.NewStyle().Border(lipgloss.NormalBorder())
+
+	m.styles.border = border.
+		Width(m.width - 2).
+		Height(m.height - 2).
+		Align(lipgloss.Center).
+		SetString("gossip visualizer")
+
+	m.styles.nodesStyle = border.
+		Width(m.width - 4).
+		Height(nodeHeight)
+
+	m.styles.controls = border.
+		Width(m.width - 4).
+		Height(controlsHeight)
+
+	m.styles.inputStyle = border.
+		Align(lipgloss.Left).
+		Width(m.width / 8).
+		Height(1)
+
+	m.styles.directionStyle = m.renderer.NewStyle().
+		Width(m.width - 4 - inputsPerRow*(m.width/8+2) - 4).
+		MarginLeft(4)
+}
+
+func (m model) View() string {
+
+	var message string
+	switch {
+	case m.extraMessage != "":
+		message = m.extraMessage
+	case m.programStep == simulationRunning && m.running:
+		message = m.runningStatus()
+	default:
+		message = m.directions[m.programStep]
+	}
+	if m.warning != "" && m.extraMessage == "" {
+		message = m.warning + "\n" + message
+	}
+
+	var rows []string
+	for row := range slices.Chunk(m.inputs, inputsPerRow) {
+		var cells []string
+		for _, input := range row {
+			cells = append(cells, m.styles.inputStyle.Render(input.View()))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Center, cells...))
+	}
+
+	ctrl := lipgloss.JoinHorizontal(lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Left, rows...),
+		m.styles.directionStyle.Render(message),
+	)
+
+	return m.styles.border.Render(
+		m.styles.nodesStyle.Render(m.screenOutput),
+		m.styles.controls.Render(ctrl),
+	)
+
+}
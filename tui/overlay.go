@@ -0,0 +1,28 @@
+package tui
+
+import "github.com/nolanjannotta/gossip-protocol-visualizer/engine"
+
+// loadEdges works out where the links of the topology cross the canvas.
+func (m *model) loadEdges(edges []engine.Edge) {
+	m.edges = make(map[[2]int]string)
+	for _, edge := range edges {
+		trace(m.edges, edge.From, edge.To, m.styles.edge)
+	}
+}
+
+// loadLinks works out where the links of the overlay the protocol gossips
+// over cross the canvas. Links that are part of a broadcast tree are drawn
+// over the others.
+func (m *model) loadLinks(edges []engine.Edge) {
+	m.links = make(map[[2]int]string)
+	for _, edge := range edges {
+		if !edge.Tree {
+			trace(m.links, edge.From, edge.To, m.styles.link)
+		}
+	}
+	for _, edge := range edges {
+		if edge.Tree {
+			trace(m.links, edge.From, edge.To, m.styles.tree)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/nolanjannotta/gossip-protocol-visualizer/engine"
+)
+
+// partitioning reports whether partitions can be drawn on the canvas: once
+// the nodes are loaded, and until the simulation finishes.
+func (m *model) partitioning() bool {
+	if m.simulation == nil || m.hasError {
+		return false
+	}
+	return m.programStep == chooseStartingNode || (m.programStep == simulationRunning && m.running)
+}
+
+// drawPartition lets the user drag out a partition with the mouse, and adds
+// it to the simulation once the button is released. The right button always
+// draws; the left one only does while the simulation is running, since it
+// picks the starting node before then. While it is running, a click that
+// does not drag kills the node under it instead, or under a CRDT protocol
+// applies an update to it, undoing one with the right button. It reports
+// whether msg was used.
+func (m *model) drawPartition(msg tea.MouseMsg) bool {
+	point := [2]int{
+		min(max(msg.X-2, 0), m.canvasWidth-1), // substracting offset
+		min(max(msg.Y-3, 0), m.canvasHeight-1),
+	}
+
+	switch msg.Action {
+	case tea.MouseActionPress:
+		if msg.Button != tea.MouseButtonRight && (msg.Button != tea.MouseButtonLeft || !m.running) {
+			return false
+		}
+		m.drawFrom, m.drawTo = &point, &point
+		m.drawButton = msg.Button
+
+	case tea.MouseActionMotion:
+		if m.drawFrom == nil {
+			return false
+		}
+		m.drawTo = &point
+
+	case tea.MouseActionRelease:
+		if m.drawFrom == nil {
+			return false
+		}
+		if *m.drawFrom != point {
+			m.simulation.AddPartition(*m.drawFrom, point, m.drawRect, m.heal)
+			// shown straight away, until the simulation reports the
+			// partitions it has in place
+			m.partitions = append(m.partitions, engine.Partition{From: *m.drawFrom, To: point, Rect: m.drawRect})
+		} else if m.running && m.simulation.Updatable() {
+			m.simulation.Update(point[0], point[1], m.drawButton == tea.MouseButtonRight)
+		} else if m.running {
+			m.simulation.Kill(point[0], point[1])
+		}
+		m.drawFrom, m.drawTo = nil, nil
+
+	default:
+		return false
+	}
+
+	m.loadFences()
+	m.drawPixels()
+	return true
+}
+
+// loadFences works out where the boundaries of the partitions, and of the
+// one being drawn, cross the canvas.
+func (m *model) loadFences() {
+	m.fences = make(map[[2]int]string)
+	for _, p := range m.partitions {
+		m.fence(p, m.styles.partition)
+	}
+	if m.drawFrom != nil {
+		m.fence(engine.Partition{From: *m.drawFrom, To: *m.drawTo, Rect: m.drawRect}, m.styles.drawing)
+	}
+}
+
+func (m *model) fence(p engine.Partition, style lipgloss.Style) {
+	if p.Rect {
+		left, right := min(p.From[0], p.To[0]), max(p.From[0], p.To[0])
+		top, bottom := min(p.From[1], p.To[1]), max(p.From[1], p.To[1])
+		for x := left; x <= right; x++ {
+			m.fences[[2]int{x, top}] = style.Render(horizontalGlyph)
+			m.fences[[2]int{x, bottom}] = style.Render(horizontalGlyph)
+		}
+		for y := top; y <= bottom; y++ {
+			m.fences[[2]int{left, y}] = style.Render(verticalGlyph)
+			m.fences[[2]int{right, y}] = style.Render(verticalGlyph)
+		}
+		for i, x := range [2]int{left, right} {
+			for j, y := range [2]int{top, bottom} {
+				m.fences[[2]int{x, y}] = style.Render(cornerGlyphs[i][j])
+			}
+		}
+		return
+	}
+
+	trace(m.fences, p.From, p.To, style)
+}
+
+// trace draws the line from from to to into cells, in style.
+func trace(cells map[[2]int]string, from, to [2]int, style lipgloss.Style) {
+	points := line(from, to)
+	for i, point := range points {
+		step := i
+		if step == 0 {
+			step = 1
+		}
+		if step >= len(points) {
+			cells[point] = style.Render(verticalGlyph)
+			continue
+		}
+		cells[point] = style.Render(lineGlyph(points[step][0]-points[step-1][0], points[step][1]-points[step-1][1]))
+	}
+}
+
+// lineGlyph returns the glyph for a line taking a step of dx, dy cells.
+func lineGlyph(dx, dy int) string {
+	switch {
+	case dy == 0:
+		return horizontalGlyph
+	case dx == 0:
+		return verticalGlyph
+	case dx*dy > 0: // y grows downwards
+		return fallingGlyph
+	}
+	return risingGlyph
+}
+
+// line returns the cells on the line from from to to, in order, using
+// Bresenham's algorithm.
+func line(from, to [2]int) [][2]int {
+	dx, dy := abs(to[0]-from[0]), -abs(to[1]-from[1])
+	sx, sy := sign(to[0]-from[0]), sign(to[1]-from[1])
+	diff := dx + dy
+
+	var points [][2]int
+	x, y := from[0], from[1]
+	for {
+		points = append(points, [2]int{x, y})
+		if x == to[0] && y == to[1] {
+			return points
+		}
+		double := 2 * diff
+		if double >= dy {
+			diff += dy
+			x += sx
+		}
+		if double <= dx {
+			diff += dx
+			y += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nolanjannotta/gossip-protocol-visualizer/engine"
+)
+
+const (
+	relayFrames   = 4                     // frames a relay takes to travel from sender to receiver
+	frameInterval = 60 * time.Millisecond // time between frames
+	maxRelays     = 256                   // relays animated at once, the rest are not shown
+	relayGlyph    = "•"                   // the head of a relay on its way
+)
+
+// relay is a rumour travelling from the node that told it to the one it
+// informed.
+type relay struct {
+	path  [][2]int // cells from sender to receiver
+	frame int
+}
+
+// frameMsg advances the relays of run runID by a frame.
+type frameMsg struct {
+	runID int
+}
+
+// addRelay animates a relay from from to to, unless too many are already
+// on their way.
+func (m *model) addRelay(from, to [2]int) {
+	if len(m.relays) >= maxRelays || from == to {
+		return
+	}
+	m.relays = append(m.relays, relay{path: line(from, to)})
+}
+
+// startAnimation schedules the next frame, if there are relays to animate
+// and none is scheduled yet.
+func (m *model) startAnimation() tea.Cmd {
+	if m.animating || len(m.relays) == 0 {
+		return nil
+	}
+	m.animating = true
+	runID := m.runID
+	return tea.Tick(frameInterval, func(time.Time) tea.Msg { return frameMsg{runID: runID} })
+}
+
+// animate moves every relay on by a frame, dropping those that arrived, and
+// schedules the next frame while any are left.
+func (m *model) animate() tea.Cmd {
+	m.animating = false
+	kept := m.relays[:0]
+	for _, r := range m.relays {
+		r.frame++
+		if r.frame < relayFrames {
+			kept = append(kept, r)
+		}
+	}
+	m.relays = kept
+	m.loadRelays()
+	m.drawPixels()
+	return m.startAnimation()
+}
+
+// loadRelays works out where the relays cross the canvas: a trail from the
+// sender to how far each has travelled, and its head.
+func (m *model) loadRelays() {
+	m.relayCells = make(map[[2]int]string)
+	head := m.styles.states[engine.Infected]
+	for _, r := range m.relays {
+		at := (len(r.path) - 1) * (r.frame + 1) / relayFrames
+		trace(m.relayCells, r.path[0], r.path[at], m.styles.trail)
+		m.relayCells[r.path[at]] = head.Render(relayGlyph)
+	}
+}